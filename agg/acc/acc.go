@@ -0,0 +1,161 @@
+// Package acc provides accumulator expressions for use in the $group,
+// $bucket, $bucketAuto, and $setWindowFields aggregation stages.
+//
+// Accumulators live in their own package so their names don't collide with
+// aggregation stages that share a name, like the $count stage and the $count
+// accumulator.
+package acc
+
+import (
+	"github.com/matthewdale/mongo-go-exp/agg"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func AddToSet(expr any) agg.Operator {
+	return agg.Operator{{Key: "$addToSet", Value: expr}}
+}
+
+func Avg(numExpr any) agg.Operator {
+	return agg.Operator{{Key: "$avg", Value: numExpr}}
+}
+
+func Bottom(outputExpr any, sortBys ...agg.SortBy) agg.Operator {
+	return agg.Operator{{
+		Key: "$bottom",
+		Value: bson.D{{
+			Key:   "sortBy",
+			Value: sortBysToD(sortBys),
+		}, {
+			Key:   "output",
+			Value: outputExpr,
+		}},
+	}}
+}
+
+func BottomN(outputExpr any, n int64, sortBys ...agg.SortBy) agg.Operator {
+	return BottomNExpr(outputExpr, n, sortBys...)
+}
+
+func BottomNExpr(outputExpr, nExpr any, sortBys ...agg.SortBy) agg.Operator {
+	return agg.Operator{{
+		Key: "$bottomN",
+		Value: bson.D{{
+			Key:   "n",
+			Value: nExpr,
+		}, {
+			Key:   "sortBy",
+			Value: sortBysToD(sortBys),
+		}, {
+			Key:   "output",
+			Value: outputExpr,
+		}},
+	}}
+}
+
+func Count() agg.Operator {
+	return agg.Operator{{Key: "$count", Value: bson.D{}}}
+}
+
+func First(expr any) agg.Operator {
+	return agg.Operator{{Key: "$first", Value: expr}}
+}
+
+func Last(expr any) agg.Operator {
+	return agg.Operator{{Key: "$last", Value: expr}}
+}
+
+func Max(expr any) agg.Operator {
+	return agg.Operator{{Key: "$max", Value: expr}}
+}
+
+// Median returns an approximation of the median of the numeric values of
+// inputExpr. It can also be used as a window operator or, with an array
+// input, as an expression.
+func Median(inputExpr any) agg.Operator {
+	return agg.Operator{{
+		Key: "$median",
+		Value: bson.D{
+			{Key: "input", Value: inputExpr},
+			{Key: "method", Value: "approximate"},
+		},
+	}}
+}
+
+func MergeObjects(documentExpr any) agg.Operator {
+	return agg.Operator{{Key: "$mergeObjects", Value: documentExpr}}
+}
+
+func Min(expr any) agg.Operator {
+	return agg.Operator{{Key: "$min", Value: expr}}
+}
+
+// Percentile returns an array of approximations of the percentiles p (each
+// between 0 and 1) of the numeric values of inputExpr. Like Median, it can
+// also be used as a window operator or expression.
+func Percentile(inputExpr any, p ...float64) agg.Operator {
+	return agg.Operator{{
+		Key: "$percentile",
+		Value: bson.D{
+			{Key: "input", Value: inputExpr},
+			{Key: "p", Value: append([]float64(nil), p...)},
+			{Key: "method", Value: "approximate"},
+		},
+	}}
+}
+
+func Push(expr any) agg.Operator {
+	return agg.Operator{{Key: "$push", Value: expr}}
+}
+
+func StdDevPop(numExpr any) agg.Operator {
+	return agg.Operator{{Key: "$stdDevPop", Value: numExpr}}
+}
+
+func StdDevSamp(numExpr any) agg.Operator {
+	return agg.Operator{{Key: "$stdDevSamp", Value: numExpr}}
+}
+
+func Sum(numExpr any) agg.Operator {
+	return agg.Operator{{Key: "$sum", Value: numExpr}}
+}
+
+func Top(outputExpr any, sortBys ...agg.SortBy) agg.Operator {
+	return agg.Operator{{
+		Key: "$top",
+		Value: bson.D{{
+			Key:   "sortBy",
+			Value: sortBysToD(sortBys),
+		}, {
+			Key:   "output",
+			Value: outputExpr,
+		}},
+	}}
+}
+
+func TopN(outputExpr any, n int64, sortBys ...agg.SortBy) agg.Operator {
+	return TopNExpr(outputExpr, n, sortBys...)
+}
+
+func TopNExpr(outputExpr, nExpr any, sortBys ...agg.SortBy) agg.Operator {
+	return agg.Operator{{
+		Key: "$topN",
+		Value: bson.D{{
+			Key:   "n",
+			Value: nExpr,
+		}, {
+			Key:   "sortBy",
+			Value: sortBysToD(sortBys),
+		}, {
+			Key:   "output",
+			Value: outputExpr,
+		}},
+	}}
+}
+
+func sortBysToD(sorts []agg.SortBy) bson.D {
+	d := make(bson.D, len(sorts))
+	for i := range sorts {
+		d[i] = bson.E(sorts[i])
+	}
+	return d
+}
@@ -0,0 +1,33 @@
+package acc
+
+import (
+	"strings"
+
+	"github.com/matthewdale/mongo-go-exp/agg"
+)
+
+// Stats returns a $group stage that summarizes the numeric field across all
+// input documents, outputting one document with the fields min, max, avg,
+// stdDev (population standard deviation), median, and percentiles. Non-numeric
+// and missing values are ignored. The median and percentiles are approximate.
+// Requires MongoDB 7.0.
+//
+// $percentile always outputs an array, so percentiles is the array
+// [p95, p99]. Use agg.ArrayElemAt in a later stage to get a single value.
+func Stats(field string) agg.Stage {
+	return StatsBy(field, nil)
+}
+
+// StatsBy is like Stats, but outputs one summary document for each distinct
+// value of partitionBy, which is in the _id field.
+func StatsBy(field string, partitionBy any) agg.Stage {
+	path := "$" + strings.TrimPrefix(field, "$")
+	return agg.Group(partitionBy,
+		agg.Field("min", Min(path)),
+		agg.Field("max", Max(path)),
+		agg.Field("avg", Avg(path)),
+		agg.Field("stdDev", StdDevPop(path)),
+		agg.Field("median", Median(path)),
+		agg.Field("percentiles", Percentile(path, 0.95, 0.99)),
+	)
+}
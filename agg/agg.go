@@ -10,6 +10,14 @@ func Field(name string, expr any) FieldExpr {
 	return FieldExpr{Key: name, Value: expr}
 }
 
+func fieldExprsToD(fields []FieldExpr) bson.D {
+	d := make(bson.D, len(fields))
+	for i := range fields {
+		d[i] = bson.E(fields[i])
+	}
+	return d
+}
+
 type SortBy bson.E
 
 func SortAscending(fieldName string) SortBy {
@@ -31,3 +39,9 @@ func sortBysToD(sorts []SortBy) bson.D {
 	}
 	return d
 }
+
+func exprsToA(exprs []any) bson.A {
+	a := make(bson.A, len(exprs))
+	copy(a, exprs)
+	return a
+}
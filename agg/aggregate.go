@@ -0,0 +1,25 @@
+package agg
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Aggregate runs the pipeline against the collection. If the pipeline is
+// tagged with a comment (see Named and Pipeline.WithComment), the comment is
+// also sent as the aggregate "comment" option unless opts set a different
+// comment.
+func Aggregate(
+	ctx context.Context,
+	coll *mongo.Collection,
+	pipeline Pipeline,
+	opts ...*options.AggregateOptions,
+) (*mongo.Cursor, error) {
+	if comment, ok := pipeline.Comment(); ok {
+		// Prepend the comment option so any comment set by the caller wins.
+		opts = append([]*options.AggregateOptions{options.Aggregate().SetComment(comment)}, opts...)
+	}
+	return coll.Aggregate(ctx, pipeline, opts...)
+}
@@ -0,0 +1,31 @@
+package agg
+
+// SumOfArray returns the sum of the numbers in the array.
+func SumOfArray(arrExpr any) Operator {
+	return Reduce(arrExpr, 0, Add("$$value", "$$this"))
+}
+
+// AvgOfArray returns the average of the numbers in the array, or null if the
+// array is empty.
+func AvgOfArray(arrExpr any) Operator {
+	return Cond(
+		Eq(Size(arrExpr), 0),
+		nil,
+		Divide(SumOfArray(arrExpr), Size(arrExpr)))
+}
+
+// CountWhere returns the number of array elements for which condExpr is true.
+// Use "$$this" in condExpr to refer to the current element.
+//
+// For example, to count the line items that cost more than 100:
+//
+//	agg.CountWhere("$items", agg.Gt("$$this.price", 100))
+func CountWhere(arrExpr, condExpr any) Operator {
+	return Size(Filter(arrExpr, "", condExpr, nil))
+}
+
+// PluckField returns an array of the values of field from each document in
+// the array.
+func PluckField(arrExpr any, field string) Operator {
+	return Map(arrExpr, "", "$$this."+field)
+}
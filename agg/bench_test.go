@@ -0,0 +1,101 @@
+package agg
+
+import (
+	"strconv"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Constructors don't use pooled builders: every stage and operator body
+// escapes to the caller, so a pooled buffer has to be copied into a new slice
+// before it's returned, which allocates just as much as preallocating the body
+// and adds the cost of the copy and the pool.
+
+// sink keeps the compiler from optimizing away benchmarked constructors.
+var sink any
+
+var benchFields = func() []FieldExpr {
+	fields := make([]FieldExpr, 20)
+	for i := range fields {
+		fields[i] = Field("field"+strconv.Itoa(i), "$source"+strconv.Itoa(i))
+	}
+	return fields
+}()
+
+func BenchmarkAddFields(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = AddFields(benchFields...)
+	}
+}
+
+func BenchmarkProject(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = Project(benchFields...)
+	}
+}
+
+func BenchmarkGroup(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = Group("$color",
+			Field("total", Sum("$qty")),
+			Field("n", CountAccumulator()),
+			Field("top", Top("$name", SortDescending("qty"))),
+		)
+	}
+}
+
+func BenchmarkFilter(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = Filter("$items", "item", Eq("$$item.ok", true), 10)
+	}
+}
+
+func BenchmarkMap(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = Map("$items", "item", "$$item.name")
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = []bson.D{
+			Match(bson.D{{Key: "status", Value: "A"}}),
+			AddFields(benchFields...),
+			Unwind("$items"),
+			Group("$items.sku",
+				Field("total", Sum("$items.qty")),
+				Field("max", Max("$items.qty")),
+				Field("names", MergeObjects("$a", "$b")),
+			),
+			Sort(SortDescending("total"), SortAscending("_id")),
+			Project(benchFields...),
+			Unset("tmp"),
+		}
+	}
+}
+
+func BenchmarkPipelineMarshal(b *testing.B) {
+	pipeline := []bson.D{
+		Match(bson.D{{Key: "status", Value: "A"}}),
+		AddFields(benchFields...),
+		Group("$sku", Field("total", Sum("$qty"))),
+		Sort(SortDescending("total")),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, stage := range pipeline {
+			if _, err := bson.Marshal(stage); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package agg
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// BoolExpr is a boolean aggregation expression that can be combined with
+// other expressions by chaining And, Or, and Not. Chained $and and $or
+// expressions are flattened, so
+//
+//	agg.Expr(a).And(b).And(c).Or(d)
+//
+// builds {$or: [{$and: [a, b, c]}, d]}, not nested two-element $and arrays.
+type BoolExpr struct {
+	expr any
+}
+
+// Expr starts a chain of boolean expressions with expr.
+func Expr(expr any) BoolExpr {
+	if b, ok := expr.(BoolExpr); ok {
+		return b
+	}
+	return BoolExpr{expr: expr}
+}
+
+func (b BoolExpr) And(exprs ...any) BoolExpr {
+	return b.combine("$and", exprs)
+}
+
+func (b BoolExpr) Or(exprs ...any) BoolExpr {
+	return b.combine("$or", exprs)
+}
+
+// Not negates the expression. Negating a negated boolean expression (e.g. a
+// comparison) removes the negation rather than nesting another $not. Other
+// expressions keep both, since {$not: {$not: x}} converts x to a boolean.
+func (b BoolExpr) Not() BoolExpr {
+	if args, ok := logicalArgs("$not", b.expr); ok && len(args) == 1 && isBoolValued(args[0]) {
+		return BoolExpr{expr: args[0]}
+	}
+	return BoolExpr{expr: Not(b.expr)}
+}
+
+// Value returns the built expression.
+func (b BoolExpr) Value() any {
+	return b.expr
+}
+
+func (b BoolExpr) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if b.expr == nil {
+		return bsontype.Null, nil, nil
+	}
+	return bson.MarshalValue(b.expr)
+}
+
+func (b BoolExpr) combine(op string, exprs []any) BoolExpr {
+	if len(exprs) == 0 {
+		return b
+	}
+
+	terms := make(bson.A, 0, len(exprs)+1)
+	for _, expr := range append([]any{b.expr}, exprs...) {
+		if e, ok := expr.(BoolExpr); ok {
+			expr = e.expr
+		}
+		if args, ok := logicalArgs(op, expr); ok {
+			terms = append(terms, args...)
+			continue
+		}
+		terms = append(terms, expr)
+	}
+	return BoolExpr{expr: Operator{{Key: op, Value: terms}}}
+}
+
+// logicalArgs returns the arguments of expr if it is an Operator for the
+// logical operator op (e.g. "$and").
+func logicalArgs(op string, expr any) (bson.A, bool) {
+	o, ok := expr.(Operator)
+	if !ok || len(o) != 1 || o[0].Key != op {
+		return nil, false
+	}
+	args, ok := o[0].Value.(bson.A)
+	return args, ok
+}
+
+// boolOperators are the expression operators that always evaluate to a
+// boolean.
+var boolOperators = map[string]bool{
+	"$and": true, "$or": true, "$not": true,
+	"$eq": true, "$ne": true, "$gt": true, "$gte": true, "$lt": true, "$lte": true,
+	"$in": true, "$isArray": true, "$isNumber": true, "$regexMatch": true,
+	"$allElementsTrue": true, "$anyElementTrue": true, "$setEquals": true, "$setIsSubset": true,
+}
+
+// isBoolValued returns true if expr is known to evaluate to a boolean.
+func isBoolValued(expr any) bool {
+	switch e := expr.(type) {
+	case bool:
+		return true
+	case BoolExpr:
+		return isBoolValued(e.expr)
+	case Operator:
+		return len(e) == 1 && boolOperators[e[0].Key]
+	}
+	return false
+}
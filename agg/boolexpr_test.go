@@ -0,0 +1,45 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBoolExprNot(t *testing.T) {
+	tests := []struct {
+		name string
+		b    BoolExpr
+		want any
+	}{
+		{
+			name: "double negated comparison",
+			b:    Expr(Eq("$a", 1)).Not().Not(),
+			want: Eq("$a", 1),
+		},
+		{
+			name: "double negated and",
+			b:    Expr(Eq("$a", 1)).And(Eq("$b", 2)).Not().Not(),
+			want: Operator{{Key: "$and", Value: bson.A{Eq("$a", 1), Eq("$b", 2)}}},
+		},
+		{
+			name: "double negated field path",
+			b:    Expr("$name").Not().Not(),
+			want: Not(Not("$name")),
+		},
+		{
+			// The inner {$not: {$not: x}} is boolean, so it can be collapsed.
+			name: "triple negated field path",
+			b:    Expr("$name").Not().Not().Not(),
+			want: Not("$name"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.b.Value(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
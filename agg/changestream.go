@@ -0,0 +1,132 @@
+package agg
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OperationType is a change event operation type.
+type OperationType string
+
+const (
+	OpInsert       OperationType = "insert"
+	OpUpdate       OperationType = "update"
+	OpReplace      OperationType = "replace"
+	OpDelete       OperationType = "delete"
+	OpDrop         OperationType = "drop"
+	OpRename       OperationType = "rename"
+	OpDropDatabase OperationType = "dropDatabase"
+	OpInvalidate   OperationType = "invalidate"
+)
+
+// OperationTypeIn returns a change event filter that matches events with any
+// of the operation types.
+func OperationTypeIn(types ...OperationType) bson.D {
+	a := make(bson.A, len(types))
+	for i := range types {
+		a[i] = types[i]
+	}
+	return bson.D{{
+		Key:   "operationType",
+		Value: bson.D{{Key: "$in", Value: a}},
+	}}
+}
+
+// NamespaceEquals returns a change event filter that matches events in the
+// database and collection. If coll is empty, it matches events in any
+// collection in the database.
+func NamespaceEquals(db, coll string) bson.D {
+	filter := bson.D{{Key: "ns.db", Value: db}}
+	if coll != "" {
+		filter = append(filter, bson.E{Key: "ns.coll", Value: coll})
+	}
+	return filter
+}
+
+// UpdatedFieldsInclude returns a change event filter that matches update
+// events that set or remove any of the fields, any of their subfields, or any
+// of their ancestors (e.g. setting "address" for the field "address.city").
+//
+// Updated and removed field names in change events can contain dots (e.g.
+// "a.b" if only subfield "b" was set), so the filter uses an $expr instead of
+// a field path query.
+func UpdatedFieldsInclude(fields ...string) bson.D {
+	// matches returns an expression that is true if the field name nameExpr
+	// is one of the fields, one of their subfields, or one of their ancestors.
+	matches := func(nameExpr string) Operator {
+		conds := make([]any, 0, 3*len(fields))
+		for _, field := range fields {
+			conds = append(conds,
+				Eq(nameExpr, field),
+				Eq(Operator{{Key: "$indexOfBytes", Value: bson.A{nameExpr, field + "."}}}, 0),
+				Eq(Operator{{Key: "$indexOfBytes", Value: bson.A{field, Concat(nameExpr, ".")}}}, 0))
+		}
+		return Or(conds...)
+	}
+
+	updated := Filter(
+		Operator{{
+			Key:   "$objectToArray",
+			Value: IfNull("$updateDescription.updatedFields", bson.D{}),
+		}},
+		"",
+		matches("$$this.k"),
+		nil)
+	removed := Filter(
+		IfNull("$updateDescription.removedFields", bson.A{}),
+		"",
+		matches("$$this"),
+		nil)
+
+	return bson.D{{
+		Key:   "$expr",
+		Value: Or(Gt(Size(updated), 0), Gt(Size(removed), 0)),
+	}}
+}
+
+// FullDocumentMatch returns a change event filter that applies filter to the
+// event's full document, by prefixing each field in filter with
+// "fullDocument.". Top-level $and, $or, and $nor are supported; other
+// top-level operators return an error.
+//
+// Full documents are only included in insert and replace events, or update
+// events if the change stream is opened with the FullDocument option.
+func FullDocumentMatch(filter bson.D) (bson.D, error) {
+	return prefixFilter("fullDocument.", filter)
+}
+
+func prefixFilter(prefix string, filter bson.D) (bson.D, error) {
+	prefixed := make(bson.D, len(filter))
+	for i, e := range filter {
+		if !strings.HasPrefix(e.Key, "$") {
+			prefixed[i] = bson.E{Key: prefix + e.Key, Value: e.Value}
+			continue
+		}
+
+		switch e.Key {
+		case "$and", "$or", "$nor":
+		default:
+			return nil, fmt.Errorf("unsupported top-level operator %q", e.Key)
+		}
+		clauses, ok := e.Value.(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be a bson.A, got %T", e.Key, e.Value)
+		}
+		prefixedClauses := make(bson.A, len(clauses))
+		for j, clause := range clauses {
+			d, ok := clause.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("%s clauses must be bson.D, got %T", e.Key, clause)
+			}
+			var err error
+			prefixedClauses[j], err = prefixFilter(prefix, d)
+			if err != nil {
+				return nil, err
+			}
+		}
+		prefixed[i] = bson.E{Key: e.Key, Value: prefixedClauses}
+	}
+	return prefixed, nil
+}
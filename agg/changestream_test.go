@@ -0,0 +1,50 @@
+package agg
+
+import (
+	"testing"
+
+	"github.com/matthewdale/mongo-go-exp/agg/eval"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUpdatedFieldsInclude(t *testing.T) {
+	event := func(updated bson.D, removed ...string) bson.D {
+		if removed == nil {
+			removed = []string{}
+		}
+		return bson.D{{Key: "updateDescription", Value: bson.D{
+			{Key: "updatedFields", Value: updated},
+			{Key: "removedFields", Value: removed},
+		}}}
+	}
+	tests := []struct {
+		name  string
+		event bson.D
+		want  bool
+	}{
+		{name: "field", event: event(bson.D{{Key: "address.city", Value: "x"}}), want: true},
+		{name: "subfield", event: event(bson.D{{Key: "address.city.name", Value: "x"}}), want: true},
+		{name: "ancestor", event: event(bson.D{{Key: "address", Value: bson.D{{Key: "city", Value: "x"}}}}), want: true},
+		{name: "sibling", event: event(bson.D{{Key: "address.zip", Value: "x"}}), want: false},
+		{name: "field name prefix", event: event(bson.D{{Key: "address.cityName", Value: "x"}}), want: false},
+		{name: "ancestor name prefix", event: event(bson.D{{Key: "addr", Value: "x"}}), want: false},
+		{name: "removed field", event: event(bson.D{}, "address.city"), want: true},
+		{name: "removed subfield", event: event(bson.D{}, "address.city.name"), want: true},
+		{name: "removed ancestor", event: event(bson.D{}, "address"), want: true},
+		{name: "removed sibling", event: event(bson.D{}, "address.zip"), want: false},
+		{name: "other watched field", event: event(bson.D{{Key: "name", Value: "x"}}), want: true},
+		{name: "no update description", event: bson.D{{Key: "operationType", Value: "insert"}}, want: false},
+	}
+	filter := UpdatedFieldsInclude("address.city", "name")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := eval.Eval(filter[0].Value, tc.event)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
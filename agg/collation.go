@@ -0,0 +1,37 @@
+package agg
+
+import "go.mongodb.org/mongo-driver/mongo/options"
+
+// CaseInsensitive returns a collation for the locale (e.g. "en") that compares
+// strings case-insensitively but still distinguishes diacritics.
+// Pass it as the aggregate collation option (e.g. with
+// options.Aggregate().SetCollation) to make $match, $sort, $group, $eq, and
+// $sortArray compare strings case-insensitively, including non-ASCII
+// characters.
+func CaseInsensitive(locale string) *options.Collation {
+	return &options.Collation{Locale: locale, Strength: 2}
+}
+
+// EqualFold returns true if the strings expr1 and expr2 are equal ignoring
+// case. It uses $strcasecmp, which only folds ASCII characters but doesn't
+// depend on the collation. Use $eq with a CaseInsensitive collation to
+// compare other characters.
+func EqualFold(expr1, expr2 any) Operator {
+	return Eq(StrCaseCmp(expr1, expr2), 0)
+}
+
+// LowerEq returns true if the lowercase forms of expr1 and expr2 are equal.
+// It's equivalent to EqualFold, but the same ToLower normalization can be
+// used for case-insensitive $group keys so grouping and comparisons agree.
+func LowerEq(expr1, expr2 any) Operator {
+	return Eq(ToLower(expr1), ToLower(expr2))
+}
+
+// aggregateCollation returns aggregate options that set the collation, or nil
+// if collation is nil.
+func aggregateCollation(collation *options.Collation) []*options.AggregateOptions {
+	if collation == nil {
+		return nil
+	}
+	return []*options.AggregateOptions{options.Aggregate().SetCollation(collation)}
+}
@@ -0,0 +1,57 @@
+package agg
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// CommentStage returns a no-op $match stage that tags a pipeline with the
+// given comment, so the pipeline can be identified in the profiler and slow
+// query logs.
+func CommentStage(comment string) Stage {
+	return Stage{{
+		Key:   "$match",
+		Value: bson.D{{Key: "$comment", Value: comment}},
+	}}
+}
+
+// Named returns a pipeline tagged with the given name. The name is recorded
+// as a CommentStage and is sent as the aggregate "comment" option when the
+// pipeline is run with Aggregate.
+func Named(name string, stages ...Stage) Pipeline {
+	return Pipeline(stages).WithComment(name)
+}
+
+// WithComment returns a copy of the pipeline tagged with the given comment,
+// replacing any existing comment tag. The CommentStage is inserted after any
+// stages that must be first in a pipeline, like $geoNear and $search.
+func (p Pipeline) WithComment(comment string) Pipeline {
+	if i, ok := p.commentIndex(); ok {
+		p = p.RemoveStage(i)
+	}
+	return p.InsertBefore(firstStagesEnd(p), CommentStage(comment))
+}
+
+// Comment returns the comment the pipeline is tagged with, if any.
+func (p Pipeline) Comment() (string, bool) {
+	i, ok := p.commentIndex()
+	if !ok {
+		return "", false
+	}
+	body, _ := p[i][0].Value.(bson.D)
+	return body[0].Value.(string), true
+}
+
+// commentIndex returns the index of the pipeline's comment tag, which follows
+// any stages that must be first.
+func (p Pipeline) commentIndex() (int, bool) {
+	i := firstStagesEnd(p)
+	if i >= len(p) || len(p[i]) != 1 || p[i][0].Key != "$match" {
+		return 0, false
+	}
+	body, ok := p[i][0].Value.(bson.D)
+	if !ok || len(body) != 1 || body[0].Key != "$comment" {
+		return 0, false
+	}
+	if _, ok := body[0].Value.(string); !ok {
+		return 0, false
+	}
+	return i, true
+}
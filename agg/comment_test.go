@@ -0,0 +1,83 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithComment(t *testing.T) {
+	near := GeoNear(bson.D{{Key: "type", Value: "Point"}}, "dist")
+	search := Stage{{Key: "$search", Value: bson.D{}}}
+
+	tests := []struct {
+		name string
+		p    Pipeline
+		want []string
+	}{
+		{
+			name: "empty",
+			p:    Named("x"),
+			want: []string{"$match"},
+		},
+		{
+			name: "prepends comment",
+			p:    Named("x", Limit(1)),
+			want: []string{"$match", "$limit"},
+		},
+		{
+			name: "after $geoNear",
+			p:    Named("x", near, Limit(1)),
+			want: []string{"$geoNear", "$match", "$limit"},
+		},
+		{
+			name: "after $search",
+			p:    Named("x", search),
+			want: []string{"$search", "$match"},
+		},
+		{
+			name: "replaces existing comment",
+			p:    Named("x", near, Limit(1)).WithComment("y"),
+			want: []string{"$geoNear", "$match", "$limit"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.p.StageNames(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got stages %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	p := Named("x", near, Limit(1)).WithComment("y")
+	if c, ok := p.Comment(); !ok || c != "y" {
+		t.Errorf(`got comment %q, %v, want "y", true`, c, ok)
+	}
+}
+
+func TestComment(t *testing.T) {
+	near := GeoNear(bson.D{}, "dist")
+
+	tests := []struct {
+		name   string
+		p      Pipeline
+		want   string
+		wantOK bool
+	}{
+		{name: "nil", p: nil},
+		{name: "untagged", p: Pipeline{Limit(1)}},
+		{name: "leading", p: Pipeline{CommentStage("a"), Limit(1)}, want: "a", wantOK: true},
+		{name: "after first stage", p: Pipeline{near, CommentStage("a")}, want: "a", wantOK: true},
+		{name: "not after first stages", p: Pipeline{Limit(1), CommentStage("a")}},
+		{name: "non-string comment", p: Pipeline{Match(bson.D{{Key: "$comment", Value: 1}})}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.p.Comment()
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
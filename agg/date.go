@@ -0,0 +1,147 @@
+package agg
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DateAdd adds amount units to startDate. The startDate can be any date
+// expression, including a time.Time. If timezone is empty, the date is
+// computed in UTC.
+func DateAdd(startDate any, unit TimeUnit, amount any, timezone string) Operator {
+	return Operator{{Key: "$dateAdd", Value: dateMathBody(startDate, unit, amount, timezone)}}
+}
+
+// DateSubtract subtracts amount units from startDate. The startDate can be
+// any date expression, including a time.Time. If timezone is empty, the date
+// is computed in UTC.
+func DateSubtract(startDate any, unit TimeUnit, amount any, timezone string) Operator {
+	return Operator{{Key: "$dateSubtract", Value: dateMathBody(startDate, unit, amount, timezone)}}
+}
+
+func dateMathBody(startDate any, unit TimeUnit, amount any, timezone string) bson.D {
+	body := make(bson.D, 3, 4)
+	body[0] = bson.E{Key: "startDate", Value: startDate}
+	body[1] = bson.E{Key: "unit", Value: unit}
+	body[2] = bson.E{Key: "amount", Value: amount}
+	if timezone != "" {
+		body = append(body, bson.E{Key: "timezone", Value: timezone})
+	}
+	return body
+}
+
+// DurationUnit converts d into the largest time unit that represents it
+// exactly, from Hour down to Millisecond. It returns an error if d has
+// sub-millisecond precision, which BSON dates can't represent.
+//
+// Calendar units like Day are never returned because they aren't a fixed
+// duration when a timezone with daylight saving time is used.
+func DurationUnit(d time.Duration) (TimeUnit, int64, error) {
+	units := []struct {
+		unit TimeUnit
+		d    time.Duration
+	}{
+		{unit: Hour, d: time.Hour},
+		{unit: Minute, d: time.Minute},
+		{unit: Second, d: time.Second},
+		{unit: Millisecond, d: time.Millisecond},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return u.unit, int64(d / u.d), nil
+		}
+	}
+	return "", 0, fmt.Errorf("duration %v can't be represented exactly in milliseconds", d)
+}
+
+// DateAddDuration adds d to startDate. It returns an error if d can't be
+// represented exactly (see DurationUnit).
+func DateAddDuration(startDate any, d time.Duration) (Operator, error) {
+	unit, amount, err := DurationUnit(d)
+	if err != nil {
+		return nil, err
+	}
+	return DateAdd(startDate, unit, amount, ""), nil
+}
+
+// DateSubtractDuration subtracts d from startDate. It returns an error if d
+// can't be represented exactly (see DurationUnit).
+func DateSubtractDuration(startDate any, d time.Duration) (Operator, error) {
+	unit, amount, err := DurationUnit(d)
+	if err != nil {
+		return nil, err
+	}
+	return DateSubtract(startDate, unit, amount, ""), nil
+}
+
+// DateTrunc truncates date to the start of its binSize unit period. A binSize
+// of 0 or 1 truncates to a single unit. If timezone is empty, the date is
+// truncated in UTC.
+func DateTrunc(date any, unit TimeUnit, binSize int64, timezone string) Operator {
+	body := make(bson.D, 2, 4)
+	body[0] = bson.E{Key: "date", Value: date}
+	body[1] = bson.E{Key: "unit", Value: unit}
+	if binSize > 1 {
+		body = append(body, bson.E{Key: "binSize", Value: binSize})
+	}
+	if timezone != "" {
+		body = append(body, bson.E{Key: "timezone", Value: timezone})
+	}
+	return Operator{{Key: "$dateTrunc", Value: body}}
+}
+
+type DateHistogramOptions struct {
+	// Timezone is the timezone used to compute bucket boundaries. Defaults to
+	// UTC.
+	Timezone string
+
+	// CountField is the output field for the number of documents in each
+	// bucket. Defaults to "count".
+	CountField string
+
+	// FillEmpty adds buckets with a count of 0 for periods that have no
+	// documents, between the first and last non-empty buckets.
+	//
+	// Empty buckets are generated in UTC, so they may not line up with
+	// non-empty buckets if Timezone is set and unit is Day or larger.
+	FillEmpty bool
+
+	// Accumulators are additional per-bucket output fields.
+	Accumulators []FieldExpr
+}
+
+// DateHistogram returns the stages that count documents in buckets of
+// binSize units of the date field, sorted by bucket start date. Each output
+// document's "_id" is the bucket start date. The opts can be nil.
+//
+// For example, to count orders per hour:
+//
+//	agg.DateHistogram("orderDate", agg.Hour, 1, nil)
+func DateHistogram(field string, unit TimeUnit, binSize int64, opts *DateHistogramOptions) Pipeline {
+	if opts == nil {
+		opts = &DateHistogramOptions{}
+	}
+	countField := opts.CountField
+	if countField == "" {
+		countField = "count"
+	}
+	if binSize < 1 {
+		binSize = 1
+	}
+
+	accs := make([]FieldExpr, 0, len(opts.Accumulators)+1)
+	accs = append(accs, Field(countField, Sum(1)))
+	accs = append(accs, opts.Accumulators...)
+
+	p := Pipeline{
+		Group(DateTrunc(fieldPathValue(field), unit, binSize, opts.Timezone), accs...),
+	}
+	if opts.FillEmpty {
+		p = append(p,
+			Densify("_id", nil, DensifyRange{Step: binSize, Unit: unit, Bounds: "full"}),
+			AddFields(Field(countField, DefaultTo(countField, 0))))
+	}
+	return append(p, Sort(SortAscending("_id")))
+}
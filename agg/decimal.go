@@ -0,0 +1,144 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrLossyConversion is returned (wrapped) when a number can't be converted to
+// or from a Decimal128 without losing precision or range.
+var ErrLossyConversion = errors.New("lossy conversion")
+
+// Decimal converts v to a Decimal128 for use in an expression. Supported types
+// are primitive.Decimal128, *big.Int, *big.Float, int, int32, int64, float64,
+// and decimal strings like "12.50".
+//
+// Integers and strings are converted exactly. Floats are converted using the
+// shortest decimal representation that rounds back to the same value (e.g.
+// 0.1 becomes 0.1, not 0.1000000000000000055511151231257827). Values that
+// need more than 34 significant digits or are out of the Decimal128 exponent
+// range return an error wrapping ErrLossyConversion.
+func Decimal(v any) (primitive.Decimal128, error) {
+	switch v := v.(type) {
+	case primitive.Decimal128:
+		return v, nil
+	case *big.Int:
+		if v == nil {
+			return primitive.Decimal128{}, errors.New("can't convert nil *big.Int to Decimal128")
+		}
+		return parseDecimal(bigIntString(v))
+	case *big.Float:
+		if v == nil {
+			return primitive.Decimal128{}, errors.New("can't convert nil *big.Float to Decimal128")
+		}
+		if v.IsInf() {
+			return infDecimal(v.Sign()), nil
+		}
+		return parseDecimal(v.Text('e', -1))
+	case int:
+		return parseDecimal(strconv.Itoa(v))
+	case int32:
+		return parseDecimal(strconv.FormatInt(int64(v), 10))
+	case int64:
+		return parseDecimal(strconv.FormatInt(v, 10))
+	case float64:
+		if math.IsInf(v, 0) {
+			return infDecimal(int(math.Copysign(1, v))), nil
+		}
+		return parseDecimal(strconv.FormatFloat(v, 'e', -1, 64))
+	case string:
+		return parseDecimal(v)
+	}
+	return primitive.Decimal128{}, fmt.Errorf("can't convert %T to Decimal128", v)
+}
+
+func parseDecimal(s string) (primitive.Decimal128, error) {
+	d, err := primitive.ParseDecimal128(s)
+	if err != nil {
+		return primitive.Decimal128{}, fmt.Errorf("%w: %q can't be represented as a Decimal128", ErrLossyConversion, s)
+	}
+	return d, nil
+}
+
+// bigIntString returns the decimal representation of i. If i has more than
+// the 34 digits a Decimal128 can hold, trailing zeros are moved to the
+// exponent so large round numbers can still be represented.
+func bigIntString(i *big.Int) string {
+	s := i.Text(10)
+	if len(strings.TrimPrefix(s, "-")) <= 34 {
+		return s
+	}
+	digits := strings.TrimRight(s, "0")
+	return digits + "E+" + strconv.Itoa(len(s)-len(digits))
+}
+
+func infDecimal(sign int) primitive.Decimal128 {
+	s := "Infinity"
+	if sign < 0 {
+		s = "-Infinity"
+	}
+	d, _ := primitive.ParseDecimal128(s)
+	return d
+}
+
+// DecimalBigInt converts d to a *big.Int. It returns an error wrapping
+// ErrLossyConversion if d has a fractional part, or an error if d is NaN or
+// infinite.
+func DecimalBigInt(d primitive.Decimal128) (*big.Int, error) {
+	i, exp, err := d.BigInt()
+	if err != nil {
+		return nil, err
+	}
+	if exp >= 0 {
+		return i.Mul(i, pow10(exp)), nil
+	}
+
+	q, r := new(big.Int).QuoRem(i, pow10(-exp), new(big.Int))
+	if r.Sign() != 0 {
+		return nil, fmt.Errorf("%w: %s is not an integer", ErrLossyConversion, d)
+	}
+	return q, nil
+}
+
+// DecimalBigFloat converts d to a *big.Float with the given precision (in
+// bits), rounding to nearest even. Most decimal fractions (e.g. 0.1) can't be
+// represented exactly in binary, so the accuracy of the result is returned
+// instead of an error for rounded values, like big.Float's conversions. It
+// returns an error if d is NaN.
+func DecimalBigFloat(d primitive.Decimal128, prec uint) (*big.Float, big.Accuracy, error) {
+	f := new(big.Float).SetPrec(prec)
+	if sign := d.IsInf(); sign != 0 {
+		return f.SetInf(sign < 0), big.Exact, nil
+	}
+
+	i, exp, err := d.BigInt()
+	if err != nil {
+		return nil, big.Exact, err
+	}
+	r := new(big.Rat).SetInt(i)
+	if exp >= 0 {
+		r.Mul(r, new(big.Rat).SetInt(pow10(exp)))
+	} else {
+		r.Quo(r, new(big.Rat).SetInt(pow10(-exp)))
+	}
+	f.SetRat(r)
+	return f, f.Acc(), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// DivideDecimal divides numeratorExpr by denomExpr after converting both to
+// Decimal128 with $toDecimal, avoiding binary floating-point rounding in
+// monetary calculations. If the denominator is zero, it returns fallbackExpr
+// instead of failing the aggregation.
+func DivideDecimal(numeratorExpr, denomExpr, fallbackExpr any) Operator {
+	return SafeDivide(ToDecimal(numeratorExpr), ToDecimal(denomExpr), fallbackExpr)
+}
@@ -0,0 +1,435 @@
+package agg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// Definition is a pipeline that can be stored as JSON or YAML configuration
+// and bound to parameter values when it's loaded. Definitions implement the
+// json and yaml (gopkg.in/yaml.v3) Marshaler and Unmarshaler interfaces, and
+// use the following format:
+//
+//	name: daily_sales
+//	params:
+//	  since: {required: true}
+//	  limit: {default: 10}
+//	pipeline:
+//	  - $match: {ts: {$gte: {$param: since}}}
+//	  - $sort: {total: -1}
+//	  - $limit: {$param: limit}
+//
+// Values use relaxed Extended JSON, so types without a JSON equivalent can be
+// written like {$date: "2024-01-02T00:00:00Z"}. YAML timestamps are decoded
+// as dates.
+type Definition struct {
+	Name   string
+	Params []ParamSpec
+
+	// Pipeline is the pipeline, with Param placeholders where parameter
+	// values are substituted by Bind.
+	Pipeline Pipeline
+}
+
+// ParamSpec declares a Definition parameter.
+type ParamSpec struct {
+	Name     string
+	Required bool
+	// Default is the value used if an optional parameter isn't bound.
+	Default any
+}
+
+// Param returns a placeholder for the named Definition parameter.
+func Param(name string) bson.D {
+	return bson.D{{Key: "$param", Value: name}}
+}
+
+// ParseDefinitionJSON parses a JSON pipeline definition.
+func ParseDefinitionJSON(data []byte) (Definition, error) {
+	var d Definition
+	err := d.UnmarshalJSON(data)
+	return d, err
+}
+
+// ParseDefinitionYAML parses a YAML pipeline definition.
+func ParseDefinitionYAML(data []byte) (Definition, error) {
+	var d Definition
+	err := yaml.Unmarshal(data, &d)
+	return d, err
+}
+
+// Bind returns the definition's pipeline with each Param placeholder replaced
+// by its value from args or its default. It returns an error if a required
+// parameter is missing, args contains an undeclared parameter, or the bound
+// pipeline contains an unknown or misplaced stage.
+func (d Definition) Bind(args map[string]any) (Pipeline, error) {
+	values := make(map[string]any, len(d.Params))
+	var errs []error
+	for _, p := range d.Params {
+		v, ok := args[p.Name]
+		switch {
+		case ok:
+			values[p.Name] = v
+		case p.Required:
+			errs = append(errs, fmt.Errorf("missing required parameter %q", p.Name))
+		default:
+			values[p.Name] = p.Default
+		}
+	}
+	for name := range args {
+		if !d.hasParam(name) {
+			errs = append(errs, fmt.Errorf("unknown parameter %q", name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	p := make(Pipeline, len(d.Pipeline))
+	for i, s := range d.Pipeline {
+		v, err := bindParams(s, values)
+		if err != nil {
+			return nil, fmt.Errorf("error binding stage %d: %w", i, err)
+		}
+		stage, ok := v.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("stage %d must be a document, got %T", i, v)
+		}
+		p[i] = stage
+	}
+	if err := validateStages(p); err != nil {
+		return nil, fmt.Errorf("invalid pipeline %q: %w", d.Name, err)
+	}
+	return p, nil
+}
+
+func (d Definition) hasParam(name string) bool {
+	for _, p := range d.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bindParams returns a copy of v with Param placeholders replaced. Only
+// documents and arrays built from bson.D, Operator, bson.A, and []any are
+// searched for placeholders.
+func bindParams(v any, values map[string]any) (any, error) {
+	switch v := v.(type) {
+	case bson.D:
+		if len(v) == 1 && v[0].Key == "$param" {
+			name, ok := v[0].Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("parameter name must be a string, got %T", v[0].Value)
+			}
+			value, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("undeclared parameter %q", name)
+			}
+			return value, nil
+		}
+		c := make(bson.D, len(v))
+		for i, e := range v {
+			bv, err := bindParams(e.Value, values)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = bson.E{Key: e.Key, Value: bv}
+		}
+		return c, nil
+	case Operator:
+		c, err := bindParams(bson.D(v), values)
+		if err != nil {
+			return nil, err
+		}
+		if d, ok := c.(bson.D); ok {
+			return Operator(d), nil
+		}
+		return c, nil
+	case bson.A:
+		c := make(bson.A, len(v))
+		for i := range v {
+			bv, err := bindParams(v[i], values)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = bv
+		}
+		return c, nil
+	case []any:
+		c, err := bindParams(bson.A(v), values)
+		if err != nil {
+			return nil, err
+		}
+		return []any(c.(bson.A)), nil
+	}
+	return v, nil
+}
+
+// validateStages returns an error if p contains a stage that isn't a
+// single-field document with a known stage name, or a stage that must be
+// first or last in another position.
+func validateStages(p Pipeline) error {
+	var errs []error
+	for i, s := range p {
+		name := stageName(s)
+		info, ok := LookupStage(name)
+		switch {
+		case name == "":
+			errs = append(errs, fmt.Errorf("stage %d must be a document with exactly 1 field", i))
+		case !ok:
+			errs = append(errs, fmt.Errorf("stage %d: unknown stage %q", i, name))
+		case info.First && i != 0:
+			errs = append(errs, fmt.Errorf("stage %d: %s must be the first stage", i, name))
+		case info.Last && i != len(p)-1:
+			errs = append(errs, fmt.Errorf("stage %d: %s must be the last stage", i, name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d Definition) document() bson.D {
+	doc := make(bson.D, 0, 3)
+	if d.Name != "" {
+		doc = append(doc, bson.E{Key: "name", Value: d.Name})
+	}
+	if len(d.Params) > 0 {
+		params := make(bson.D, len(d.Params))
+		for i, p := range d.Params {
+			spec := bson.D{}
+			if p.Required {
+				spec = append(spec, bson.E{Key: "required", Value: true})
+			}
+			if p.Default != nil {
+				spec = append(spec, bson.E{Key: "default", Value: p.Default})
+			}
+			params[i] = bson.E{Key: p.Name, Value: spec}
+		}
+		doc = append(doc, bson.E{Key: "params", Value: params})
+	}
+	return append(doc, bson.E{Key: "pipeline", Value: nonNilPipeline(d.Pipeline)})
+}
+
+func (d *Definition) setDocument(doc bson.D) error {
+	*d = Definition{}
+	for _, e := range doc {
+		switch e.Key {
+		case "name":
+			name, ok := e.Value.(string)
+			if !ok {
+				return fmt.Errorf("name must be a string, got %T", e.Value)
+			}
+			d.Name = name
+		case "params":
+			params, ok := e.Value.(bson.D)
+			if !ok {
+				return fmt.Errorf("params must be a document, got %T", e.Value)
+			}
+			for _, pe := range params {
+				p, err := parseParamSpec(pe)
+				if err != nil {
+					return err
+				}
+				d.Params = append(d.Params, p)
+			}
+		case "pipeline":
+			stages, ok := e.Value.(bson.A)
+			if !ok {
+				return fmt.Errorf("pipeline must be an array, got %T", e.Value)
+			}
+			d.Pipeline = make(Pipeline, len(stages))
+			for i, s := range stages {
+				stage, ok := s.(bson.D)
+				if !ok {
+					return fmt.Errorf("pipeline stage %d must be a document, got %T", i, s)
+				}
+				d.Pipeline[i] = stage
+			}
+		default:
+			return fmt.Errorf("unknown definition field %q", e.Key)
+		}
+	}
+	return nil
+}
+
+func parseParamSpec(e bson.E) (ParamSpec, error) {
+	p := ParamSpec{Name: e.Key}
+	spec, ok := e.Value.(bson.D)
+	if !ok {
+		return p, fmt.Errorf("parameter %q must be a document, got %T", e.Key, e.Value)
+	}
+	for _, se := range spec {
+		switch se.Key {
+		case "required":
+			required, ok := se.Value.(bool)
+			if !ok {
+				return p, fmt.Errorf("parameter %q: required must be a bool, got %T", e.Key, se.Value)
+			}
+			p.Required = required
+		case "default":
+			p.Default = se.Value
+		default:
+			return p, fmt.Errorf("parameter %q: unknown field %q", e.Key, se.Key)
+		}
+	}
+	return p, nil
+}
+
+// MarshalJSON returns the definition as relaxed Extended JSON.
+func (d Definition) MarshalJSON() ([]byte, error) {
+	return bson.MarshalExtJSON(d.document(), false, false)
+}
+
+// UnmarshalJSON parses a definition from relaxed or canonical Extended JSON.
+func (d *Definition) UnmarshalJSON(data []byte) error {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return fmt.Errorf("error parsing pipeline definition: %w", err)
+	}
+	return d.setDocument(doc)
+}
+
+// MarshalYAML returns the definition as a YAML node in block style.
+func (d Definition) MarshalYAML() (any, error) {
+	b, err := d.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, so decoding it as YAML preserves the field order.
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return node.Content[0], nil
+}
+
+func blockStyle(n *yaml.Node) {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!str" {
+		n.Style = 0
+	} else {
+		// Keep quotes only where needed to keep strings from being decoded as
+		// another type, like "10" or "true".
+		n.Style = 0
+		var v any
+		if err := yaml.Unmarshal([]byte(n.Value), &v); err != nil || v != n.Value {
+			n.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// UnmarshalYAML parses a definition from a YAML node.
+func (d *Definition) UnmarshalYAML(value *yaml.Node) error {
+	var buf bytes.Buffer
+	if err := yamlToJSON(&buf, value); err != nil {
+		return fmt.Errorf("error parsing pipeline definition: %w", err)
+	}
+	return d.UnmarshalJSON(buf.Bytes())
+}
+
+// yamlToJSON writes n as Extended JSON, preserving the order of mapping keys.
+func yamlToJSON(buf *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return yamlToJSON(buf, n.Content[0])
+	case yaml.AliasNode:
+		return yamlToJSON(buf, n.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(n.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := yamlToJSON(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, c := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := yamlToJSON(buf, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	switch n.ShortTag() {
+	case "!!null":
+		buf.WriteString("null")
+	case "!!bool", "!!int":
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case "!!float":
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		// JSON has no infinity or NaN literals.
+		switch {
+		case math.IsNaN(f):
+			buf.WriteString(`{"$numberDouble":"NaN"}`)
+			return nil
+		case math.IsInf(f, 1):
+			buf.WriteString(`{"$numberDouble":"Infinity"}`)
+			return nil
+		case math.IsInf(f, -1):
+			buf.WriteString(`{"$numberDouble":"-Infinity"}`)
+			return nil
+		}
+		if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+			// Keep integral floats as doubles instead of integers.
+			buf.WriteString(strconv.FormatFloat(f, 'f', 1, 64))
+		} else {
+			buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case "!!timestamp":
+		var t time.Time
+		if err := n.Decode(&t); err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		fmt.Fprintf(buf, `{"$date":%q}`, t.UTC().Format(time.RFC3339Nano))
+	default:
+		b, err := json.Marshal(n.Value)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package agg
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+var testDefinition = Definition{
+	Name: "daily_sales",
+	Params: []ParamSpec{
+		{Name: "since", Required: true},
+		{Name: "limit", Default: int32(10)},
+	},
+	Pipeline: Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "ts", Value: bson.D{{Key: "$gte", Value: Param("since")}}},
+			{Key: "code", Value: "10"},
+			{Key: "flag", Value: "true"},
+			{Key: "ratio", Value: 2.0},
+			{Key: "big", Value: int64(3000000000)},
+			{Key: "start", Value: primitive.NewDateTimeFromTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))},
+			{Key: "max", Value: math.Inf(1)},
+			{Key: "min", Value: math.Inf(-1)},
+			{Key: "none", Value: nil},
+		}}},
+		{{Key: "$limit", Value: Param("limit")}},
+	},
+}
+
+func TestDefinitionRoundTrip(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		b, err := testDefinition.MarshalJSON()
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		got, err := ParseDefinitionJSON(b)
+		if err != nil {
+			t.Fatalf("error parsing %s: %v", b, err)
+		}
+		if !reflect.DeepEqual(got, testDefinition) {
+			t.Errorf("got %+v, want %+v", got, testDefinition)
+		}
+	})
+	t.Run("YAML", func(t *testing.T) {
+		b, err := yaml.Marshal(testDefinition)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		got, err := ParseDefinitionYAML(b)
+		if err != nil {
+			t.Fatalf("error parsing %s: %v", b, err)
+		}
+		if !reflect.DeepEqual(got, testDefinition) {
+			t.Errorf("got %+v, want %+v\nYAML:\n%s", got, testDefinition, b)
+		}
+	})
+}
+
+func TestParseDefinitionYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want any
+	}{
+		{name: "int", yaml: "10", want: int32(10)},
+		{name: "large int", yaml: "3000000000", want: int64(3000000000)},
+		{name: "integral float", yaml: "10.0", want: 10.0},
+		{name: "float", yaml: "1.5", want: 1.5},
+		{name: "exponent float", yaml: "1e300", want: 1e300},
+		{name: "quoted int", yaml: `"10"`, want: "10"},
+		{name: "quoted float", yaml: `'1.5'`, want: "1.5"},
+		{name: "quoted bool", yaml: `"true"`, want: "true"},
+		{name: "bool", yaml: "true", want: true},
+		{name: "null", yaml: "null", want: nil},
+		{name: "string", yaml: "abc", want: "abc"},
+		{name: "infinity", yaml: ".inf", want: math.Inf(1)},
+		{name: "negative infinity", yaml: "-.inf", want: math.Inf(-1)},
+		{name: "date", yaml: "2024-01-02", want: primitive.NewDateTimeFromTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))},
+		{
+			name: "timestamp",
+			yaml: "2024-01-02T03:04:05.5+01:00",
+			want: primitive.NewDateTimeFromTime(time.Date(2024, 1, 2, 2, 4, 5, 5e8, time.UTC)),
+		},
+		{name: "extended JSON", yaml: `{$numberLong: "5"}`, want: int64(5)},
+		{name: "flow document", yaml: "{b: 1, a: 2}", want: bson.D{{Key: "b", Value: int32(1)}, {Key: "a", Value: int32(2)}}},
+		{name: "anchor", yaml: "&x [1]", want: bson.A{int32(1)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := ParseDefinitionYAML([]byte("pipeline: [{$match: {v: " + tc.yaml + "}}]"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := Pipeline{{{Key: "$match", Value: bson.D{{Key: "v", Value: tc.want}}}}}
+			if !reflect.DeepEqual(d.Pipeline, want) {
+				t.Errorf("got %#v, want %#v", d.Pipeline, want)
+			}
+		})
+	}
+
+	t.Run("NaN", func(t *testing.T) {
+		d, err := ParseDefinitionYAML([]byte("pipeline: [{$match: {v: .nan}}]"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		v := d.Pipeline[0][0].Value.(bson.D)[0].Value
+		if f, ok := v.(float64); !ok || !math.IsNaN(f) {
+			t.Errorf("got %#v, want NaN", v)
+		}
+	})
+}
+
+func TestParseDefinitionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		yaml string
+	}{
+		{name: "unknown field", json: `{"pipeline": [], "stages": []}`, yaml: "stages: []"},
+		{name: "name not a string", json: `{"name": 1}`, yaml: "name: [a]"},
+		{name: "params not a document", json: `{"params": ["a"]}`, yaml: "params: [a]"},
+		{name: "param not a document", json: `{"params": {"a": 1}}`, yaml: "params: {a: 1}"},
+		{name: "required not a bool", json: `{"params": {"a": {"required": "yes"}}}`, yaml: "params: {a: {required: yes}}"},
+		{name: "unknown param field", json: `{"params": {"a": {"type": "int"}}}`, yaml: "params: {a: {type: int}}"},
+		{name: "pipeline not an array", json: `{"pipeline": {}}`, yaml: "pipeline: {}"},
+		{name: "stage not a document", json: `{"pipeline": [1]}`, yaml: "pipeline: [1]"},
+		{name: "invalid syntax", json: `{"pipeline": [}`, yaml: "pipeline: [{$match: {v: !!int abc}}]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseDefinitionJSON([]byte(tc.json)); err == nil {
+				t.Errorf("got no error parsing JSON %s, want an error", tc.json)
+			}
+			if _, err := ParseDefinitionYAML([]byte(tc.yaml)); err == nil {
+				t.Errorf("got no error parsing YAML %s, want an error", tc.yaml)
+			}
+		})
+	}
+}
+
+func TestDefinitionBind(t *testing.T) {
+	since := primitive.NewDateTimeFromTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name    string
+		d       Definition
+		args    map[string]any
+		want    Pipeline
+		wantErr string
+	}{
+		{
+			name: "default",
+			d:    testDefinition,
+			args: map[string]any{"since": since},
+			want: Pipeline{
+				{{Key: "$match", Value: func() bson.D {
+					m := cloneD(testDefinition.Pipeline[0][0].Value.(bson.D))
+					m[0].Value = bson.D{{Key: "$gte", Value: since}}
+					return m
+				}()}},
+				{{Key: "$limit", Value: int32(10)}},
+			},
+		},
+		{
+			name: "all params",
+			d: Definition{
+				Params:   []ParamSpec{{Name: "n", Required: true}},
+				Pipeline: Pipeline{Limit(1), Project(Field("a", bson.A{Param("n"), []any{Param("n")}}))},
+			},
+			args: map[string]any{"n": 5},
+			want: Pipeline{Limit(1), Project(Field("a", bson.A{5, []any{5}}))},
+		},
+		{
+			name: "nil default",
+			d: Definition{
+				Params:   []ParamSpec{{Name: "n"}},
+				Pipeline: Pipeline{Match(bson.D{{Key: "a", Value: Param("n")}})},
+			},
+			want: Pipeline{Match(bson.D{{Key: "a", Value: nil}})},
+		},
+		{
+			name:    "missing required parameter",
+			d:       testDefinition,
+			args:    map[string]any{"limit": 5},
+			wantErr: `missing required parameter "since"`,
+		},
+		{
+			name:    "unknown parameter",
+			d:       testDefinition,
+			args:    map[string]any{"since": since, "skip": 5},
+			wantErr: `unknown parameter "skip"`,
+		},
+		{
+			name:    "undeclared parameter",
+			d:       Definition{Pipeline: Pipeline{{{Key: "$limit", Value: Param("n")}}}},
+			wantErr: `undeclared parameter "n"`,
+		},
+		{
+			name:    "parameter name not a string",
+			d:       Definition{Pipeline: Pipeline{{{Key: "$limit", Value: bson.D{{Key: "$param", Value: 1}}}}}},
+			wantErr: "parameter name must be a string",
+		},
+		{
+			name: "stage parameter not a document",
+			d: Definition{
+				Params:   []ParamSpec{{Name: "s", Default: "x"}},
+				Pipeline: Pipeline{Param("s")},
+			},
+			wantErr: "stage 0 must be a document",
+		},
+		{
+			name:    "unknown stage",
+			d:       Definition{Name: "x", Pipeline: Pipeline{{{Key: "$matchh", Value: bson.D{}}}}},
+			wantErr: `unknown stage "$matchh"`,
+		},
+		{
+			name:    "multiple fields",
+			d:       Definition{Pipeline: Pipeline{{{Key: "$match", Value: bson.D{}}, {Key: "$limit", Value: 1}}}},
+			wantErr: "stage 0 must be a document with exactly 1 field",
+		},
+		{
+			name:    "first stage not first",
+			d:       Definition{Pipeline: Pipeline{Limit(1), GeoNear(bson.D{}, "dist")}},
+			wantErr: "$geoNear must be the first stage",
+		},
+		{
+			name:    "last stage not last",
+			d:       Definition{Pipeline: Pipeline{Out("x"), Limit(1)}},
+			wantErr: "$out must be the last stage",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.d.Bind(tc.args)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want an error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONSpecialFloats(t *testing.T) {
+	for yamlValue, want := range map[string]string{
+		".inf":  `{"$numberDouble":"Infinity"}`,
+		"-.inf": `{"$numberDouble":"-Infinity"}`,
+		".nan":  `{"$numberDouble":"NaN"}`,
+	} {
+		var n yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlValue), &n); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := yamlToJSON(&buf, &n); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("got %s for %s, want %s", got, yamlValue, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxDensifyDocuments is the server's default limit on the number of
+// documents a single $densify stage can generate, set by the
+// internalQueryMaxAllowedDensifyDocs server parameter.
+const MaxDensifyDocuments = 500000
+
+// ErrDensifyTooLarge is returned by CheckDensify if a $densify stage would
+// generate too many documents.
+var ErrDensifyTooLarge = errors.New("$densify would generate too many documents")
+
+// DocumentCount returns the number of values of the densified field in the
+// range, which is the most documents $densify can generate for each partition.
+// It returns false if the count depends on the input documents (i.e. the
+// bounds are "full" or "partition") or the range can't be evaluated, like if
+// the bounds are expressions.
+func (r DensifyRange) DocumentCount() (int64, bool) {
+	lower, upper, ok := densifyBounds(r.Bounds)
+	if !ok {
+		return 0, false
+	}
+	return densifyCount(lower, upper, r.Step, r.Unit)
+}
+
+// CheckDensify returns an error wrapping ErrDensifyTooLarge if a $densify
+// stage in p, including in sub-pipelines, has explicit bounds that would
+// generate more than max documents for each partition. Use MaxDensifyDocuments
+// to check against the server's default limit before sending the pipeline.
+//
+// Stages with "full" or "partition" bounds generate documents based on the
+// input documents, so they can't be checked.
+func CheckDensify(p Pipeline, max int64) error {
+	for i, s := range p {
+		if stageName(s) == "$densify" {
+			if n, ok := stageDensifyRange(s).DocumentCount(); ok && n > max {
+				return fmt.Errorf("stage %d: %w: %d documents per partition, limit %d",
+					i, ErrDensifyTooLarge, n, max)
+			}
+		}
+		for _, sub := range stageSubPipelines(s) {
+			if err := CheckDensify(sub, max); err != nil {
+				return fmt.Errorf("stage %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DensifyOption sets optional behaviors of a Densify stage.
+type DensifyOption func(rng *DensifyRange)
+
+// WithMaxDocuments caps the number of documents the stage generates for each
+// partition at n by moving the upper bound down, if the range has explicit
+// bounds. Ranges with "full" or "partition" bounds aren't changed.
+func WithMaxDocuments(n int64) DensifyOption {
+	return func(rng *DensifyRange) {
+		lower, upper, ok := densifyBounds(rng.Bounds)
+		if !ok {
+			return
+		}
+		if count, ok := densifyCount(lower, upper, rng.Step, rng.Unit); !ok || count <= n {
+			return
+		}
+		if capped, ok := densifyAdvance(lower, rng.Step, rng.Unit, n); ok {
+			rng.Bounds = []any{lower, capped}
+		}
+	}
+}
+
+func stageDensifyRange(s Stage) DensifyRange {
+	var rng DensifyRange
+	r, _ := lookupKey(stageBody(s), "range")
+	rng.Step, _ = lookupKey(r, "step")
+	if unit, ok := lookupKey(r, "unit"); ok {
+		switch unit := unit.(type) {
+		case TimeUnit:
+			rng.Unit = unit
+		case string:
+			rng.Unit = TimeUnit(unit)
+		}
+	}
+	rng.Bounds, _ = lookupKey(r, "bounds")
+	return rng
+}
+
+// densifyBounds returns the lower and upper bounds if bounds is a
+// two-element array.
+func densifyBounds(bounds any) (any, any, bool) {
+	v := reflect.ValueOf(bounds)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array || v.Len() != 2 {
+		return nil, nil, false
+	}
+	return v.Index(0).Interface(), v.Index(1).Interface(), true
+}
+
+func densifyCount(lower, upper, step any, unit TimeUnit) (int64, bool) {
+	if unit == "" {
+		lo, ok1 := densifyNumber(lower)
+		hi, ok2 := densifyNumber(upper)
+		st, ok3 := densifyNumber(step)
+		if !ok1 || !ok2 || !ok3 || st <= 0 {
+			return 0, false
+		}
+		if hi <= lo {
+			return 0, true
+		}
+		n := math.Ceil((hi - lo) / st)
+		if n > math.MaxInt64 {
+			return math.MaxInt64, true
+		}
+		return int64(n), true
+	}
+
+	lo, ok1 := densifyDate(lower)
+	hi, ok2 := densifyDate(upper)
+	st, ok3 := densifyNumber(step)
+	if !ok1 || !ok2 || !ok3 || st <= 0 || st != math.Trunc(st) {
+		return 0, false
+	}
+	if !hi.After(lo) {
+		return 0, true
+	}
+	if months, ok := calendarMonths(unit); ok {
+		stepMonths := int64(st) * months
+		diff := int64(hi.Year()-lo.Year())*12 + int64(hi.Month()-lo.Month())
+		// Start just below the estimate and count up, since months have
+		// different lengths.
+		n := diff/stepMonths - 1
+		if n < 0 {
+			n = 0
+		}
+		for lo.AddDate(0, int(n*stepMonths), 0).Before(hi) {
+			n++
+		}
+		return n, true
+	}
+	d, ok := unitDuration(unit)
+	if !ok {
+		return 0, false
+	}
+	stepMillis := int64(st) * d.Milliseconds()
+	diff := hi.UnixMilli() - lo.UnixMilli()
+	return (diff + stepMillis - 1) / stepMillis, true
+}
+
+// densifyAdvance returns lower plus n steps.
+func densifyAdvance(lower, step any, unit TimeUnit, n int64) (any, bool) {
+	if unit == "" {
+		lo, ok1 := densifyNumber(lower)
+		st, ok2 := densifyNumber(step)
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		hi := lo + float64(n)*st
+		if hi == math.Trunc(hi) && isInteger(lower) && isInteger(step) {
+			return int64(hi), true
+		}
+		return hi, true
+	}
+
+	lo, ok1 := densifyDate(lower)
+	st, ok2 := densifyNumber(step)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	if months, ok := calendarMonths(unit); ok {
+		return lo.AddDate(0, int(n*int64(st)*months), 0), true
+	}
+	d, ok := unitDuration(unit)
+	if !ok {
+		return nil, false
+	}
+	return lo.Add(time.Duration(n*int64(st)) * d), true
+}
+
+func densifyNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func isInteger(v any) bool {
+	switch v.(type) {
+	case int, int32, int64:
+		return true
+	}
+	return false
+}
+
+func densifyDate(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC(), true
+	case primitive.DateTime:
+		return v.Time().UTC(), true
+	}
+	return time.Time{}, false
+}
+
+func calendarMonths(unit TimeUnit) (int64, bool) {
+	switch unit {
+	case Month:
+		return 1, true
+	case Quarter:
+		return 3, true
+	case Year:
+		return 12, true
+	}
+	return 0, false
+}
+
+func unitDuration(unit TimeUnit) (time.Duration, bool) {
+	switch unit {
+	case Week:
+		return 7 * 24 * time.Hour, true
+	case Day:
+		return 24 * time.Hour, true
+	case Hour:
+		return time.Hour, true
+	case Minute:
+		return time.Minute, true
+	case Second:
+		return time.Second, true
+	case Millisecond:
+		return time.Millisecond, true
+	}
+	return 0, false
+}
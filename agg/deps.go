@@ -0,0 +1,370 @@
+package agg
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StageDependencies describes the fields a stage reads and writes.
+type StageDependencies struct {
+	// Consumes is the sorted list of field paths the stage reads. It's empty
+	// if ConsumesAll is true.
+	Consumes []string
+	// ConsumesAll is true if the stage may read any field, like a stage that
+	// references $$ROOT or an unknown stage.
+	ConsumesAll bool
+
+	// Produces is the sorted list of fields the stage adds, replaces, or
+	// removes.
+	Produces []string
+	// ReplacesDocument is true if the stage's output documents only contain
+	// the fields it produces, like $group and inclusion $project stages.
+	ReplacesDocument bool
+}
+
+// Dependencies returns the field dependencies of each stage in p.
+func (p Pipeline) Dependencies() []StageDependencies {
+	deps := make([]StageDependencies, len(p))
+	for i, s := range p {
+		deps[i] = stageDependencies(s)
+	}
+	return deps
+}
+
+func stageDependencies(s Stage) StageDependencies {
+	body := stageBody(s)
+	var deps StageDependencies
+	consumes := func(v any) {
+		refs, all := exprRefs(v)
+		deps.Consumes = append(deps.Consumes, refs...)
+		deps.ConsumesAll = deps.ConsumesAll || all
+	}
+
+	switch stageName(s) {
+	case "$match":
+		d, _ := docElems(body)
+		for _, e := range d {
+			if e.Key == "$text" || e.Key == "$where" {
+				deps.ConsumesAll = true
+			}
+		}
+		consumes(body)
+		deps.Consumes = append(deps.Consumes, filterFields(body)...)
+	case "$sort":
+		d, _ := docElems(body)
+		for _, e := range d {
+			deps.Consumes = append(deps.Consumes, e.Key)
+		}
+	case "$limit", "$skip", "$sample", "$unionWith":
+	case "$addFields", "$set":
+		consumes(body)
+		deps.Produces = docKeys(body)
+	case "$unset":
+		deps.Produces = unsetFields(body)
+	case "$project":
+		inclusion, fields := projection(body)
+		if !inclusion {
+			for f := range fields {
+				deps.Produces = append(deps.Produces, f)
+			}
+			break
+		}
+		for _, e := range projectionSpec(body) {
+			if fields[e.Key] && !isProjectionFlag(e.Value) {
+				consumes(e.Value)
+			} else if fields[e.Key] {
+				deps.Consumes = append(deps.Consumes, e.Key)
+			}
+		}
+		if _, excluded := fields["_id"]; !excluded {
+			deps.Consumes = append(deps.Consumes, "_id")
+		}
+		deps.Produces = docKeys(body)
+		deps.ReplacesDocument = true
+	case "$group":
+		consumes(body)
+		deps.Produces = docKeys(body)
+		deps.ReplacesDocument = true
+	case "$bucket", "$bucketAuto":
+		consumes(body)
+		deps.Produces = []string{"_id", "count"}
+		if out, ok := lookupKey(body, "output"); ok {
+			deps.Produces = append(docKeys(out), "_id")
+		}
+		deps.ReplacesDocument = true
+	case "$sortByCount":
+		consumes(body)
+		deps.Produces = []string{"_id", "count"}
+		deps.ReplacesDocument = true
+	case "$count":
+		if name, ok := body.(string); ok {
+			deps.Produces = []string{name}
+		}
+		deps.ReplacesDocument = true
+	case "$replaceRoot":
+		root, _ := lookupKey(body, "newRoot")
+		consumes(root)
+		deps.ReplacesDocument = true
+	case "$replaceWith":
+		consumes(body)
+		deps.ReplacesDocument = true
+	case "$unwind":
+		path, _ := body.(string)
+		if p, ok := lookupKey(body, "path"); ok {
+			path, _ = p.(string)
+		}
+		path = strings.TrimPrefix(path, "$")
+		deps.Consumes = []string{path}
+		deps.Produces = []string{path}
+		if idx, ok := lookupKey(body, "includeArrayIndex"); ok {
+			if s, ok := idx.(string); ok {
+				deps.Produces = append(deps.Produces, s)
+			}
+		}
+	case "$lookup", "$graphLookup":
+		if lf, ok := lookupKey(body, "localField"); ok {
+			if s, ok := lf.(string); ok {
+				deps.Consumes = append(deps.Consumes, s)
+			}
+		}
+		if let, ok := lookupKey(body, "let"); ok {
+			consumes(let)
+		}
+		if sw, ok := lookupKey(body, "startWith"); ok {
+			consumes(sw)
+		}
+		if as, ok := lookupKey(body, "as"); ok {
+			if s, ok := as.(string); ok {
+				deps.Produces = []string{s}
+			}
+		}
+	case "$setWindowFields", "$fill":
+		consumes(body)
+		deps.Consumes = append(deps.Consumes, stageFieldRefs(s)...)
+		if out, ok := lookupKey(body, "output"); ok {
+			deps.Produces = docKeys(out)
+		}
+	case "$densify":
+		deps.Consumes = stageFieldRefs(s)
+	case "$facet":
+		for _, sub := range stageSubPipelines(s) {
+			needed := requiredFields(sub.Dependencies(), allFields())
+			if needed.all {
+				deps.ConsumesAll = true
+			}
+			for f := range needed.paths {
+				deps.Consumes = append(deps.Consumes, f)
+			}
+		}
+		deps.Produces = docKeys(body)
+		deps.ReplacesDocument = true
+	default:
+		deps.ConsumesAll = true
+	}
+
+	if deps.ConsumesAll {
+		deps.Consumes = nil
+	}
+	deps.Consumes = sortedUnique(deps.Consumes)
+	deps.Produces = sortedUnique(deps.Produces)
+	return deps
+}
+
+// exprRefs returns the field paths referenced in the expression v, and
+// whether v references the whole document with $$ROOT or $$CURRENT.
+func exprRefs(v any) ([]string, bool) {
+	var refs []string
+	all := false
+	walkValue(v, func(v any) bool {
+		s, ok := v.(string)
+		if !ok {
+			return true
+		}
+		switch {
+		case isFieldPath(s):
+			refs = append(refs, s[1:])
+		case s == "$$ROOT" || s == "$$CURRENT":
+			all = true
+		case strings.HasPrefix(s, "$$ROOT."):
+			refs = append(refs, strings.TrimPrefix(s, "$$ROOT."))
+		case strings.HasPrefix(s, "$$CURRENT."):
+			refs = append(refs, strings.TrimPrefix(s, "$$CURRENT."))
+		}
+		return true
+	})
+	return refs, all
+}
+
+func docKeys(v any) []string {
+	d, _ := docElems(v)
+	keys := make([]string, len(d))
+	for i, e := range d {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+func sortedUnique(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	out := s[:1]
+	for _, v := range s[1:] {
+		if v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// fieldSet is a set of field paths, or all fields.
+type fieldSet struct {
+	all   bool
+	paths map[string]bool
+}
+
+func allFields() fieldSet {
+	return fieldSet{all: true}
+}
+
+// needs reports whether any path in the set is, contains, or is contained in
+// path.
+func (fs fieldSet) needs(path string) bool {
+	if fs.all {
+		return true
+	}
+	for p := range fs.paths {
+		if pathsOverlap(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// before returns the fields needed before a stage with deps, given the fields
+// needed after it.
+func (fs fieldSet) before(deps StageDependencies) fieldSet {
+	if deps.ConsumesAll {
+		return allFields()
+	}
+	if fs.all && !deps.ReplacesDocument {
+		return fs
+	}
+
+	paths := make(map[string]bool)
+	if !deps.ReplacesDocument {
+	next:
+		for p := range fs.paths {
+			for _, prod := range deps.Produces {
+				if p == prod || strings.HasPrefix(p, prod+".") {
+					continue next
+				}
+			}
+			paths[p] = true
+		}
+	}
+	for _, c := range deps.Consumes {
+		paths[c] = true
+	}
+	return fieldSet{paths: paths}
+}
+
+// requiredFields returns the fields needed from the input of a pipeline with
+// the given stage dependencies, given the fields needed from its output.
+func requiredFields(deps []StageDependencies, needed fieldSet) fieldSet {
+	for i := len(deps) - 1; i >= 0; i-- {
+		needed = needed.before(deps[i])
+	}
+	return needed
+}
+
+// PruneProjections returns a copy of p with an inclusion $project stage
+// added at the start that keeps only the fields the rest of the pipeline
+// needs, and with fields that later stages don't use removed from existing
+// inclusion $project stages. Dropping unneeded fields early reduces the memory
+// used by stages like $group and $sort for large documents.
+//
+// Pipelines that may use every field, like those that return whole documents
+// or reference $$ROOT, are returned unchanged apart from copying.
+func (p Pipeline) PruneProjections() Pipeline {
+	out := p.Clone()
+	needed := allFields()
+	for i := len(out) - 1; i >= 0; i-- {
+		if stageName(out[i]) == "$project" && !needed.all {
+			out[i] = trimProjection(out[i], needed)
+		}
+		needed = needed.before(stageDependencies(out[i]))
+	}
+	if needed.all {
+		return out
+	}
+
+	start := leadingStagesEnd(out)
+	if start < len(out) && stageDependencies(out[start]).ReplacesDocument {
+		return out
+	}
+	return out.InsertBefore(start, Stage{{Key: "$project", Value: projectionOf(needed)}})
+}
+
+// trimProjection removes fields that aren't needed from an inclusion
+// $project stage.
+func trimProjection(s Stage, needed fieldSet) Stage {
+	body := stageBody(s)
+	if inclusion, _ := projection(body); !inclusion {
+		return s
+	}
+	d, _ := docElems(body)
+
+	trimmed := make(bson.D, 0, len(d))
+	kept := 0
+	for _, e := range d {
+		if e.Key == "_id" || needed.needs(e.Key) {
+			trimmed = append(trimmed, e)
+			if e.Key != "_id" {
+				kept++
+			}
+		}
+	}
+	if kept == 0 {
+		// A projection of only {_id: 0} would exclude _id instead of including
+		// nothing else.
+		trimmed = bson.D{{Key: "_id", Value: 1}}
+	}
+	return Stage{{Key: "$project", Value: trimmed}}
+}
+
+// projectionOf returns an inclusion projection of the fields in fs, removing
+// paths contained in other included paths to avoid path collisions.
+func projectionOf(fs fieldSet) bson.D {
+	paths := make([]string, 0, len(fs.paths))
+	for p := range fs.paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	proj := bson.D{}
+	hasID := false
+next:
+	for _, p := range paths {
+		for _, e := range proj {
+			if strings.HasPrefix(p, e.Key+".") {
+				continue next
+			}
+		}
+		if p == "_id" || strings.HasPrefix(p, "_id.") {
+			hasID = true
+		}
+		proj = append(proj, bson.E{Key: p, Value: 1})
+	}
+	if !hasID {
+		if len(proj) == 0 {
+			return bson.D{{Key: "_id", Value: 1}}
+		}
+		proj = append(proj, bson.E{Key: "_id", Value: 0})
+	}
+	return proj
+}
@@ -0,0 +1,127 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStageDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Stage
+		want StageDependencies
+	}{
+		{
+			name: "nested inclusion projection",
+			s:    Project(Field("a", bson.D{{Key: "b", Value: 1}})),
+			want: StageDependencies{
+				Consumes:         []string{"_id", "a.b"},
+				Produces:         []string{"a"},
+				ReplacesDocument: true,
+			},
+		},
+		{
+			name: "nested computed projection",
+			s:    Project(Field("_id", 0), Field("a", bson.D{{Key: "b", Value: "$x"}})),
+			want: StageDependencies{
+				Consumes:         []string{"x"},
+				Produces:         []string{"_id", "a"},
+				ReplacesDocument: true,
+			},
+		},
+		{
+			name: "nested exclusion projection",
+			s:    Project(Field("a", bson.D{{Key: "b", Value: 0}})),
+			want: StageDependencies{Produces: []string{"a.b"}},
+		},
+		{
+			name: "$bucket with output",
+			s: Stage{{Key: "$bucket", Value: bson.D{
+				{Key: "groupBy", Value: "$price"},
+				{Key: "boundaries", Value: bson.A{0, 100}},
+				{Key: "output", Value: bson.D{{Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}}}},
+			}}},
+			want: StageDependencies{
+				Consumes:         []string{"price"},
+				Produces:         []string{"_id", "n"},
+				ReplacesDocument: true,
+			},
+		},
+		{
+			name: "$bucketAuto without output",
+			s: Stage{{Key: "$bucketAuto", Value: bson.D{
+				{Key: "groupBy", Value: "$price"},
+				{Key: "buckets", Value: 4},
+			}}},
+			want: StageDependencies{
+				Consumes:         []string{"price"},
+				Produces:         []string{"_id", "count"},
+				ReplacesDocument: true,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := stageDependencies(tc.s); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPruneProjections(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Pipeline
+		want Pipeline
+	}{
+		{
+			name: "nested inclusion projection",
+			p: Pipeline{
+				Match(bson.D{{Key: "x", Value: 1}}),
+				Project(Field("a", bson.D{{Key: "b", Value: 1}})),
+				Group("$a.b", Field("n", Operator{{Key: "$sum", Value: 1}})),
+			},
+			want: Pipeline{
+				Project(Field("_id", 1), Field("a.b", 1), Field("x", 1)),
+				Match(bson.D{{Key: "x", Value: 1}}),
+				Project(Field("a", bson.D{{Key: "b", Value: 1}})),
+				Group("$a.b", Field("n", Operator{{Key: "$sum", Value: 1}})),
+			},
+		},
+		{
+			name: "$bucket output",
+			p: Pipeline{
+				Match(bson.D{{Key: "x", Value: 1}}),
+				Stage{{Key: "$bucket", Value: bson.D{
+					{Key: "groupBy", Value: "$price"},
+					{Key: "boundaries", Value: bson.A{0, 100}},
+				}}},
+				Sort(SortDescending("count")),
+			},
+			want: Pipeline{
+				Project(Field("price", 1), Field("x", 1), Field("_id", 0)),
+				Match(bson.D{{Key: "x", Value: 1}}),
+				Stage{{Key: "$bucket", Value: bson.D{
+					{Key: "groupBy", Value: "$price"},
+					{Key: "boundaries", Value: bson.A{0, 100}},
+				}}},
+				Sort(SortDescending("count")),
+			},
+		},
+		{
+			name: "returns whole documents",
+			p:    Pipeline{Match(bson.D{{Key: "x", Value: 1}})},
+			want: Pipeline{Match(bson.D{{Key: "x", Value: 1}})},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.p.PruneProjections(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package agg
+
+// SafeDivide divides numeratorExpr by denomExpr, or returns fallbackExpr if
+// the denominator is zero. An unguarded $divide by zero fails the whole
+// aggregation. Like $divide, it returns null if either argument is null or
+// missing.
+func SafeDivide(numeratorExpr, denomExpr, fallbackExpr any) Operator {
+	return Cond(
+		Eq(denomExpr, 0),
+		fallbackExpr,
+		Divide(numeratorExpr, denomExpr))
+}
+
+// Ratio returns partExpr divided by totalExpr, or 0 if the total is zero. For
+// example, the fraction of orders that shipped:
+//
+//	agg.Field("shippedRatio", agg.Ratio("$shipped", "$orders"))
+func Ratio(partExpr, totalExpr any) Operator {
+	return SafeDivide(partExpr, totalExpr, 0)
+}
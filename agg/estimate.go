@@ -0,0 +1,120 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ErrPipelineTooComplex is returned by PipelineMetrics.Check if a pipeline
+// exceeds a limit.
+var ErrPipelineTooComplex = errors.New("pipeline is too complex")
+
+// PipelineMetrics are rough measures of the size and complexity of a
+// pipeline definition, returned by Estimate.
+type PipelineMetrics struct {
+	// Stages is the number of stages, including stages in sub-pipelines like
+	// those of $facet and $lookup.
+	Stages int
+
+	// ExprDepth is the deepest nesting of documents and arrays in any stage,
+	// where a stage like {$match: {a: 1}} has a depth of 1.
+	ExprDepth int
+
+	// Size is the size of the pipeline in bytes when encoded as BSON.
+	Size int
+
+	// Lookups is the number of $lookup and $graphLookup stages, including
+	// those in sub-pipelines.
+	Lookups int
+}
+
+// Estimate returns metrics about the pipeline definition, so services that
+// generate pipelines (e.g. from user-defined reports) can reject
+// pathologically large ones before sending them to the server. It returns an
+// error if the pipeline can't be encoded as BSON.
+func Estimate(p Pipeline) (PipelineMetrics, error) {
+	doc, err := bson.Marshal(bson.D{{Key: "pipeline", Value: p}})
+	if err != nil {
+		return PipelineMetrics{}, err
+	}
+	var m PipelineMetrics
+	m.Size = len(doc)
+
+	// A nil pipeline is encoded as null.
+	stages, _ := bson.Raw(doc).Lookup("pipeline").ArrayOK()
+	values, _ := stages.Values()
+	for _, v := range values {
+		// The stage document itself doesn't count toward the depth.
+		if d := rawDepth(v) - 1; d > m.ExprDepth {
+			m.ExprDepth = d
+		}
+	}
+
+	p.countStages(&m)
+	return m, nil
+}
+
+func (p Pipeline) countStages(m *PipelineMetrics) {
+	for _, s := range p {
+		m.Stages++
+		switch stageName(s) {
+		case "$lookup", "$graphLookup":
+			m.Lookups++
+		}
+		for _, sub := range stageSubPipelines(s) {
+			sub.countStages(m)
+		}
+	}
+}
+
+// rawDepth returns the nesting depth of documents and arrays in v, which is 0
+// for other values.
+func rawDepth(v bson.RawValue) int {
+	var values []bson.RawValue
+	switch v.Type {
+	case bsontype.EmbeddedDocument:
+		elems, _ := v.Document().Elements()
+		for _, e := range elems {
+			values = append(values, e.Value())
+		}
+	case bsontype.Array:
+		values, _ = v.Array().Values()
+	default:
+		return 0
+	}
+	depth := 0
+	for _, c := range values {
+		if d := rawDepth(c); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
+
+// PipelineLimits are the limits checked by PipelineMetrics.Check. A zero limit
+// isn't checked.
+type PipelineLimits struct {
+	MaxStages    int
+	MaxExprDepth int
+	MaxSize      int
+	MaxLookups   int
+}
+
+// Check returns an error wrapping ErrPipelineTooComplex that describes every
+// limit the metrics exceed, or nil if they're within the limits.
+func (m PipelineMetrics) Check(limits PipelineLimits) error {
+	var errs []error
+	check := func(name string, got, max int) {
+		if max > 0 && got > max {
+			errs = append(errs, fmt.Errorf("%w: %s is %d, limit %d", ErrPipelineTooComplex, name, got, max))
+		}
+	}
+	check("stage count", m.Stages, limits.MaxStages)
+	check("expression depth", m.ExprDepth, limits.MaxExprDepth)
+	check("size", m.Size, limits.MaxSize)
+	check("$lookup count", m.Lookups, limits.MaxLookups)
+	return errors.Join(errs...)
+}
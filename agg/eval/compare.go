@@ -0,0 +1,234 @@
+package eval
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// typeOrder returns the position of v's type in the BSON comparison order.
+func typeOrder(v any) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 0
+	case missingValue:
+		return 1
+	case nil, primitive.Null, primitive.Undefined:
+		return 2
+	case int32, int64, float64, primitive.Decimal128:
+		return 3
+	case string, primitive.Symbol:
+		return 4
+	case bson.D:
+		return 5
+	case bson.A:
+		return 6
+	case primitive.Binary:
+		return 7
+	case primitive.ObjectID:
+		return 8
+	case bool:
+		return 9
+	case primitive.DateTime:
+		return 10
+	case primitive.Timestamp:
+		return 11
+	case primitive.Regex:
+		return 12
+	case primitive.MaxKey:
+		return 14
+	}
+	return 13
+}
+
+// compare returns -1, 0, or 1 if a is less than, equal to, or greater than b
+// in the BSON comparison order.
+func compare(a, b any) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return cmpInt(int64(ta), int64(tb))
+	}
+
+	switch a := a.(type) {
+	case int32, int64, float64, primitive.Decimal128:
+		return compareNumbers(a, b)
+	case string:
+		return strings.Compare(a, stringOf(b))
+	case primitive.Symbol:
+		return strings.Compare(string(a), stringOf(b))
+	case bson.D:
+		bd := b.(bson.D)
+		for i := 0; i < len(a) && i < len(bd); i++ {
+			if c := cmpInt(int64(typeOrder(a[i].Value)), int64(typeOrder(bd[i].Value))); c != 0 {
+				return c
+			}
+			if c := strings.Compare(a[i].Key, bd[i].Key); c != 0 {
+				return c
+			}
+			if c := compare(a[i].Value, bd[i].Value); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(int64(len(a)), int64(len(bd)))
+	case bson.A:
+		ba := b.(bson.A)
+		for i := 0; i < len(a) && i < len(ba); i++ {
+			if c := compare(a[i], ba[i]); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(int64(len(a)), int64(len(ba)))
+	case primitive.Binary:
+		bb := b.(primitive.Binary)
+		if len(a.Data) != len(bb.Data) {
+			return cmpInt(int64(len(a.Data)), int64(len(bb.Data)))
+		}
+		if a.Subtype != bb.Subtype {
+			return cmpInt(int64(a.Subtype), int64(bb.Subtype))
+		}
+		return bytes.Compare(a.Data, bb.Data)
+	case primitive.ObjectID:
+		bo := b.(primitive.ObjectID)
+		return bytes.Compare(a[:], bo[:])
+	case bool:
+		bb := b.(bool)
+		switch {
+		case a == bb:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return cmpInt(int64(a), int64(b.(primitive.DateTime)))
+	case primitive.Timestamp:
+		return primitive.CompareTimestamp(a, b.(primitive.Timestamp))
+	case primitive.Regex:
+		br := b.(primitive.Regex)
+		if c := strings.Compare(a.Pattern, br.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Options, br.Options)
+	}
+	return 0
+}
+
+func stringOf(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case primitive.Symbol:
+		return string(v)
+	}
+	return ""
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareNumbers(a, b any) int {
+	ai, aInt := toInt64(a)
+	bi, bInt := toInt64(b)
+	if aInt && bInt {
+		return cmpInt(ai, bi)
+	}
+	_, aDec := a.(primitive.Decimal128)
+	_, bDec := b.(primitive.Decimal128)
+	if aDec || bDec {
+		return compareExact(a, b)
+	}
+
+	af, bf := toFloat(a), toFloat(b)
+	switch {
+	case math.IsNaN(af) && math.IsNaN(bf):
+		return 0
+	case math.IsNaN(af):
+		// NaN sorts before all other numbers.
+		return -1
+	case math.IsNaN(bf):
+		return 1
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+// compareExact compares numbers of any type without rounding them to a
+// common type, which is needed to compare Decimal128 values with doubles.
+func compareExact(a, b any) int {
+	ra, xa := exactNumber(a)
+	rb, xb := exactNumber(b)
+	if ra != rb {
+		return cmpInt(int64(ra), int64(rb))
+	}
+	if ra != rankFinite {
+		return 0
+	}
+	return xa.Cmp(xb)
+}
+
+// Ranks of numbers that can't be compared as rationals, in sort order.
+const (
+	rankNaN = iota
+	rankNegInf
+	rankFinite
+	rankPosInf
+)
+
+// exactNumber returns the rank of the number v and, if it's finite, its
+// exact value.
+func exactNumber(v any) (int, *big.Rat) {
+	switch v := v.(type) {
+	case int32:
+		return rankFinite, new(big.Rat).SetInt64(int64(v))
+	case int64:
+		return rankFinite, new(big.Rat).SetInt64(v)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return rankNaN, nil
+		case math.IsInf(v, -1):
+			return rankNegInf, nil
+		case math.IsInf(v, 1):
+			return rankPosInf, nil
+		}
+		return rankFinite, new(big.Rat).SetFloat64(v)
+	case primitive.Decimal128:
+		if v.IsNaN() {
+			return rankNaN, nil
+		}
+		switch v.IsInf() {
+		case -1:
+			return rankNegInf, nil
+		case 1:
+			return rankPosInf, nil
+		}
+		coef, exp, err := v.BigInt()
+		if err != nil {
+			return rankNaN, nil
+		}
+		r := new(big.Rat).SetInt(coef)
+		if exp < 0 {
+			return rankFinite, r.Quo(r, new(big.Rat).SetInt(pow10(-exp)))
+		}
+		return rankFinite, r.Mul(r, new(big.Rat).SetInt(pow10(exp)))
+	}
+	return rankNaN, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,60 @@
+package eval
+
+import (
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b any
+		want int
+	}{
+		{name: "minKey before missing", a: primitive.MinKey{}, b: missing, want: -1},
+		{name: "missing before null", a: missing, b: nil, want: -1},
+		{name: "null before numbers", a: nil, b: int32(0), want: -1},
+		{name: "numbers before strings", a: math.Inf(1), b: "", want: -1},
+		{name: "strings before documents", a: "z", b: bson.D{}, want: -1},
+		{name: "documents before arrays", a: bson.D{}, b: bson.A{}, want: -1},
+		{name: "bool before dates", a: true, b: primitive.DateTime(0), want: -1},
+		{name: "maxKey last", a: primitive.MaxKey{}, b: primitive.Regex{}, want: 1},
+
+		{name: "int32 and int64", a: int32(1), b: int64(1), want: 0},
+		{name: "int and double", a: int64(2), b: 1.5, want: 1},
+		{name: "NaN before numbers", a: math.NaN(), b: math.Inf(-1), want: -1},
+		{name: "NaNs equal", a: math.NaN(), b: math.NaN(), want: 0},
+
+		{name: "decimals", a: mustDecimal("1.10"), b: mustDecimal("1.1"), want: 0},
+		{name: "decimal and int32", a: mustDecimal("2"), b: int32(1), want: 1},
+		{name: "int64 and decimal", a: int64(math.MaxInt64), b: mustDecimal("9223372036854775806.5"), want: 1},
+		{name: "decimal and double", a: mustDecimal("0.1"), b: 0.1, want: -1},
+		{name: "double and decimal", a: 0.5, b: mustDecimal("0.5"), want: 0},
+		{name: "large decimal", a: mustDecimal("1E+400"), b: math.MaxFloat64, want: 1},
+		{name: "decimal infinity", a: mustDecimal("Infinity"), b: math.Inf(1), want: 0},
+		{name: "decimal negative infinity", a: mustDecimal("-Infinity"), b: int32(math.MinInt32), want: -1},
+		{name: "decimal NaN", a: mustDecimal("NaN"), b: math.Inf(-1), want: -1},
+		{name: "decimal NaN and NaN", a: mustDecimal("NaN"), b: math.NaN(), want: 0},
+
+		{name: "strings", a: "a", b: "b", want: -1},
+		{name: "documents by key", a: bson.D{{Key: "a", Value: 1}}, b: bson.D{{Key: "b", Value: 1}}, want: -1},
+		{name: "documents by value type", a: bson.D{{Key: "b", Value: int32(1)}}, b: bson.D{{Key: "a", Value: "x"}}, want: -1},
+		{name: "document prefix", a: bson.D{{Key: "a", Value: 1}}, b: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}, want: -1},
+		{name: "arrays", a: bson.A{int32(1), int32(3)}, b: bson.A{int32(2)}, want: -1},
+		{name: "booleans", a: true, b: false, want: 1},
+		{name: "dates", a: primitive.DateTime(2), b: primitive.DateTime(1), want: 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := compare(tc.a, tc.b); got != tc.want {
+				t.Errorf("compare(%v, %v) = %d, want %d", tc.a, tc.b, got, tc.want)
+			}
+			if got := compare(tc.b, tc.a); got != -tc.want {
+				t.Errorf("compare(%v, %v) = %d, want %d", tc.b, tc.a, got, -tc.want)
+			}
+		})
+	}
+}
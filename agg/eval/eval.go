@@ -0,0 +1,281 @@
+// Package eval evaluates aggregation expressions against documents in-process,
+// so pipeline logic can be unit tested without a running MongoDB server.
+//
+// Only a subset of expression operators is supported: arithmetic, comparison,
+// boolean, conditional, common string and array operators, $literal, $let,
+// and $type. Evaluating an unsupported operator returns an error that wraps
+// ErrUnsupportedOperator. Results are intended to match the server's, but
+// edge cases (e.g. arithmetic on Decimal128 values) may differ, so critical
+// pipelines should still be tested against a real server.
+//
+// For example:
+//
+//	v, err := eval.Eval(agg.Cond(agg.Gt("$qty", 10), "many", "few"), bson.M{"qty": 12})
+//	// v == "many"
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrUnsupportedOperator is returned (wrapped) when an expression uses an
+// operator that eval doesn't support.
+var ErrUnsupportedOperator = errors.New("unsupported operator")
+
+// missingValue is the result of referencing a field that doesn't exist,
+// which is different from a field with a null value.
+type missingValue struct{}
+
+var missing = missingValue{}
+
+// Eval evaluates the aggregation expression expr with doc as the current
+// document ("$$ROOT" and "$$CURRENT"). The doc can be any value that marshals
+// to a BSON document, like a bson.D, bson.M, or struct.
+//
+// Values in the result are the types the BSON library decodes into bson.D:
+// documents are bson.D, arrays are bson.A, and numbers are int32, int64, or
+// float64. A reference to a missing field evaluates to nil.
+func Eval(expr, doc any) (any, error) {
+	return EvalWithVars(expr, doc, nil)
+}
+
+// EvalWithVars is like Eval, but also defines the variables in vars, which can
+// be referenced as "$$<name>" in expr.
+func EvalWithVars(expr, doc any, vars map[string]any) (any, error) {
+	root := bson.D{}
+	if doc != nil {
+		b, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling document: %w", err)
+		}
+		if err := bson.Unmarshal(b, &root); err != nil {
+			return nil, fmt.Errorf("error unmarshaling document: %w", err)
+		}
+	}
+
+	e, err := normalize(expr)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling expression: %w", err)
+	}
+
+	ev := &evaluator{vars: map[string]any{"ROOT": root, "CURRENT": root}}
+	for name, v := range vars {
+		nv, err := normalize(v)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling variable %q: %w", name, err)
+		}
+		ev.vars[name] = nv
+	}
+
+	v, err := ev.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if v == missing {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// normalize converts v into the types the BSON library decodes into bson.D
+// by marshaling and unmarshaling it.
+func normalize(v any) (any, error) {
+	b, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(b, &d); err != nil {
+		return nil, err
+	}
+	return d[0].Value, nil
+}
+
+type evaluator struct {
+	vars map[string]any
+}
+
+// withVars returns an evaluator with the additional variables defined.
+func (ev *evaluator) withVars(vars map[string]any) *evaluator {
+	merged := make(map[string]any, len(ev.vars)+len(vars))
+	for k, v := range ev.vars {
+		merged[k] = v
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return &evaluator{vars: merged}
+}
+
+func (ev *evaluator) eval(e any) (any, error) {
+	switch e := e.(type) {
+	case string:
+		switch {
+		case strings.HasPrefix(e, "$$"):
+			name, path, _ := strings.Cut(e[2:], ".")
+			v, ok := ev.vars[name]
+			if !ok {
+				return nil, fmt.Errorf("use of undefined variable %q", name)
+			}
+			if path == "" {
+				return v, nil
+			}
+			return resolvePath(v, strings.Split(path, ".")), nil
+		case strings.HasPrefix(e, "$"):
+			return resolvePath(ev.vars["CURRENT"], strings.Split(e[1:], ".")), nil
+		}
+		return e, nil
+	case bson.A:
+		a := make(bson.A, len(e))
+		for i := range e {
+			v, err := ev.eval(e[i])
+			if err != nil {
+				return nil, err
+			}
+			if v == missing {
+				v = nil
+			}
+			a[i] = v
+		}
+		return a, nil
+	case bson.D:
+		if len(e) == 1 && strings.HasPrefix(e[0].Key, "$") {
+			return ev.evalOperator(e[0].Key, e[0].Value)
+		}
+		d := make(bson.D, 0, len(e))
+		for _, elem := range e {
+			if strings.HasPrefix(elem.Key, "$") {
+				return nil, fmt.Errorf("invalid field name %q in object expression", elem.Key)
+			}
+			v, err := ev.eval(elem.Value)
+			if err != nil {
+				return nil, err
+			}
+			if v == missing {
+				continue
+			}
+			d = append(d, bson.E{Key: elem.Key, Value: v})
+		}
+		return d, nil
+	}
+	return e, nil
+}
+
+func (ev *evaluator) evalOperator(name string, arg any) (any, error) {
+	if name == "$literal" {
+		return arg, nil
+	}
+	fn, ok := operators[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedOperator, name)
+	}
+	v, err := fn(ev, arg)
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// args returns the operator arguments. Operators that take a single argument
+// can be written without the array.
+func args(arg any) []any {
+	if a, ok := arg.(bson.A); ok {
+		return a
+	}
+	return []any{arg}
+}
+
+// evalArgs evaluates the operator arguments and checks the argument count is
+// within [min, max]. A max of -1 means any number of arguments.
+func (ev *evaluator) evalArgs(arg any, min, max int) ([]any, error) {
+	raw := args(arg)
+	if len(raw) < min || (max >= 0 && len(raw) > max) {
+		if min == max {
+			return nil, fmt.Errorf("expected %d arguments, got %d", min, len(raw))
+		}
+		return nil, fmt.Errorf("expected %d to %d arguments, got %d", min, max, len(raw))
+	}
+	vals := make([]any, len(raw))
+	for i := range raw {
+		v, err := ev.eval(raw[i])
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// namedArgs returns the named arguments of an operator that takes a document,
+// like $filter.
+func namedArgs(arg any, required ...string) (map[string]any, error) {
+	d, ok := arg.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("expected a document argument, got %T", arg)
+	}
+	m := make(map[string]any, len(d))
+	for _, e := range d {
+		m[e.Key] = e.Value
+	}
+	for _, r := range required {
+		if _, ok := m[r]; !ok {
+			return nil, fmt.Errorf("missing required argument %q", r)
+		}
+	}
+	return m, nil
+}
+
+// resolvePath returns the value at the field path in v. Paths through arrays
+// return an array of the values at the rest of the path in each element.
+func resolvePath(v any, parts []string) any {
+	if len(parts) == 0 {
+		return v
+	}
+	switch v := v.(type) {
+	case bson.D:
+		for _, e := range v {
+			if e.Key == parts[0] {
+				return resolvePath(e.Value, parts[1:])
+			}
+		}
+		return missing
+	case bson.A:
+		res := bson.A{}
+		for _, elem := range v {
+			switch elem.(type) {
+			case bson.D, bson.A:
+				if r := resolvePath(elem, parts); r != missing {
+					res = append(res, r)
+				}
+			}
+		}
+		return res
+	}
+	return missing
+}
+
+func isNullish(v any) bool {
+	return v == nil || v == missing
+}
+
+// truthy returns whether v is true in a boolean context. Only false, null,
+// missing, and zero are false.
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil, missingValue:
+		return false
+	case bool:
+		return v
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	}
+	return true
+}
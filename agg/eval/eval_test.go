@@ -0,0 +1,97 @@
+package eval
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEval(t *testing.T) {
+	doc := bson.D{
+		{Key: "a", Value: bson.D{{Key: "b", Value: int32(1)}}},
+		{Key: "items", Value: bson.A{
+			bson.D{{Key: "qty", Value: int32(1)}},
+			bson.D{{Key: "price", Value: 2.5}},
+			bson.D{{Key: "qty", Value: int32(3)}},
+			"scalar",
+		}},
+	}
+	tests := []struct {
+		name string
+		expr any
+		doc  any
+		want any
+	}{
+		{name: "constant", expr: "abc", doc: doc, want: "abc"},
+		{name: "field path", expr: "$a.b", doc: doc, want: int32(1)},
+		{name: "missing field", expr: "$a.c", doc: doc, want: nil},
+		{name: "path through array", expr: "$items.qty", doc: doc, want: bson.A{int32(1), int32(3)}},
+		{name: "ROOT", expr: "$$ROOT.a", doc: doc, want: bson.D{{Key: "b", Value: int32(1)}}},
+		{name: "nil document", expr: "$a", doc: nil, want: nil},
+		{name: "map document", expr: "$n", doc: bson.M{"n": 1}, want: int32(1)},
+		{name: "struct document", expr: "$n", doc: struct{ N int64 }{N: 1}, want: int64(1)},
+		{
+			name: "object expression drops missing fields",
+			expr: bson.D{{Key: "x", Value: "$a.b"}, {Key: "y", Value: "$nope"}},
+			doc:  doc,
+			want: bson.D{{Key: "x", Value: int32(1)}},
+		},
+		{
+			name: "array expression converts missing to null",
+			expr: bson.A{"$a.b", "$nope"},
+			doc:  doc,
+			want: bson.A{int32(1), nil},
+		},
+		{name: "Go int literal", expr: bson.D{{Key: "$add", Value: bson.A{1, 2}}}, doc: doc, want: int32(3)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Eval(tc.expr, tc.doc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	t.Run("unsupported operator", func(t *testing.T) {
+		_, err := Eval(bson.D{{Key: "$dateAdd", Value: bson.D{}}}, nil)
+		if !errors.Is(err, ErrUnsupportedOperator) {
+			t.Errorf("got error %v, want ErrUnsupportedOperator", err)
+		}
+	})
+	t.Run("undefined variable", func(t *testing.T) {
+		if _, err := Eval("$$nope", nil); err == nil {
+			t.Error("got no error, want an error")
+		}
+	})
+	t.Run("operator in object expression", func(t *testing.T) {
+		if _, err := Eval(bson.D{{Key: "a", Value: 1}, {Key: "$add", Value: bson.A{}}}, nil); err == nil {
+			t.Error("got no error, want an error")
+		}
+	})
+	t.Run("unmarshalable document", func(t *testing.T) {
+		if _, err := Eval("$a", make(chan int)); err == nil {
+			t.Error("got no error, want an error")
+		}
+	})
+}
+
+func TestEvalWithVars(t *testing.T) {
+	got, err := EvalWithVars(
+		bson.D{{Key: "$add", Value: bson.A{"$$n", "$$doc.x"}}},
+		nil,
+		map[string]any{"n": 1, "doc": bson.M{"x": int64(2)}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != int64(3) {
+		t.Errorf("got %#v, want int64(3)", got)
+	}
+}
@@ -0,0 +1,1146 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type operatorFunc func(ev *evaluator, arg any) (any, error)
+
+var operators map[string]operatorFunc
+
+func init() {
+	operators = map[string]operatorFunc{
+		// Arithmetic
+		"$abs":      unaryNumber(absNumber),
+		"$add":      evalAdd,
+		"$ceil":     unaryNumber(roundFunc(math.Ceil)),
+		"$divide":   evalDivide,
+		"$floor":    unaryNumber(roundFunc(math.Floor)),
+		"$mod":      evalMod,
+		"$multiply": evalMultiply,
+		"$pow":      evalPow,
+		"$round":    evalRound,
+		"$sqrt":     unaryNumber(func(v any) any { return math.Sqrt(toFloat(v)) }),
+		"$subtract": evalSubtract,
+
+		// Comparison
+		"$cmp": comparison(func(c int) any { return int32(c) }),
+		"$eq":  comparison(func(c int) any { return c == 0 }),
+		"$gt":  comparison(func(c int) any { return c > 0 }),
+		"$gte": comparison(func(c int) any { return c >= 0 }),
+		"$lt":  comparison(func(c int) any { return c < 0 }),
+		"$lte": comparison(func(c int) any { return c <= 0 }),
+		"$ne":  comparison(func(c int) any { return c != 0 }),
+
+		// Boolean
+		"$and": evalAnd,
+		"$not": evalNot,
+		"$or":  evalOr,
+
+		// Conditional
+		"$cond":   evalCond,
+		"$ifNull": evalIfNull,
+		"$switch": evalSwitch,
+
+		// String
+		"$concat":       evalConcat,
+		"$indexOfBytes": evalIndexOfBytes,
+		"$split":        evalSplit,
+		"$strLenCP":     evalStrLenCP,
+		"$substrCP":     evalSubstrCP,
+		"$toLower":      caseFunc(strings.ToLower),
+		"$toString":     evalToString,
+		"$toUpper":      caseFunc(strings.ToUpper),
+		"$trim":         evalTrim,
+
+		// Array
+		"$arrayElemAt":  evalArrayElemAt,
+		"$concatArrays": evalConcatArrays,
+		"$filter":       evalFilter,
+		"$first":        arrayEnd(true),
+		"$in":           evalIn,
+		"$isArray":      evalIsArray,
+		"$last":         arrayEnd(false),
+		"$map":          evalMap,
+		"$reduce":       evalReduce,
+		"$size":         evalSize,
+
+		// Accumulator expressions
+		"$avg": evalAvg,
+		"$max": extremum(1),
+		"$min": extremum(-1),
+		"$sum": evalSum,
+
+		// Object
+		"$mergeObjects":  evalMergeObjects,
+		"$objectToArray": evalObjectToArray,
+
+		// Type
+		"$type": evalType,
+
+		// Variable
+		"$let": evalLet,
+	}
+}
+
+func toInt64(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+func toFloat(v any) float64 {
+	switch v := v.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return math.NaN()
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int32, int64, float64:
+		return true
+	}
+	return false
+}
+
+// intResult returns n as an int32 if both operands were int32 and n fits,
+// otherwise as an int64, matching the server's integer type promotion.
+func intResult(n int64, bothInt32 bool) any {
+	if bothInt32 && n >= math.MinInt32 && n <= math.MaxInt32 {
+		return int32(n)
+	}
+	return n
+}
+
+// arith applies an arithmetic operation to two numbers, using integer math if
+// both are integers and the result doesn't overflow.
+func arith(a, b any, intOp func(a, b int64) (int64, bool), floatOp func(a, b float64) float64) any {
+	ai, aInt := toInt64(a)
+	bi, bInt := toInt64(b)
+	if aInt && bInt {
+		if n, ok := intOp(ai, bi); ok {
+			_, a32 := a.(int32)
+			_, b32 := b.(int32)
+			return intResult(n, a32 && b32)
+		}
+	}
+	return floatOp(toFloat(a), toFloat(b))
+}
+
+func addInt(a, b int64) (int64, bool) {
+	n := a + b
+	return n, (n > a) == (b > 0)
+}
+
+func subInt(a, b int64) (int64, bool) {
+	n := a - b
+	return n, (n < a) == (b > 0)
+}
+
+func mulInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	n := a * b
+	return n, n/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64)
+}
+
+func unaryNumber(fn func(any) any) operatorFunc {
+	return func(ev *evaluator, arg any) (any, error) {
+		vals, err := ev.evalArgs(arg, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(vals[0]) {
+			return nil, nil
+		}
+		if !isNumber(vals[0]) {
+			return nil, fmt.Errorf("argument must be a number, got %T", vals[0])
+		}
+		return fn(vals[0]), nil
+	}
+}
+
+// roundFunc returns integers unchanged and applies fn to doubles.
+func roundFunc(fn func(float64) float64) func(any) any {
+	return func(v any) any {
+		if _, ok := toInt64(v); ok {
+			return v
+		}
+		return fn(toFloat(v))
+	}
+}
+
+func absNumber(v any) any {
+	switch v := v.(type) {
+	case int32:
+		if v == math.MinInt32 {
+			return -int64(v)
+		}
+		if v < 0 {
+			return -v
+		}
+		return v
+	case int64:
+		if v < 0 && v != math.MinInt64 {
+			return -v
+		}
+		if v == math.MinInt64 {
+			return math.Abs(float64(v))
+		}
+		return v
+	}
+	return math.Abs(toFloat(v))
+}
+
+func evalAdd(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var sum any = int32(0)
+	var date *primitive.DateTime
+	for _, v := range vals {
+		switch v := v.(type) {
+		case nil, missingValue:
+			return nil, nil
+		case primitive.DateTime:
+			if date != nil {
+				return nil, errors.New("only one date allowed")
+			}
+			date = &v
+		case int32, int64, float64:
+			sum = arith(sum, v, addInt, func(a, b float64) float64 { return a + b })
+		default:
+			return nil, fmt.Errorf("arguments must be numbers or a date, got %T", v)
+		}
+	}
+	if date != nil {
+		return primitive.DateTime(int64(*date) + int64(math.Round(toFloat(sum)))), nil
+	}
+	return sum, nil
+}
+
+func evalSubtract(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	a, b := vals[0], vals[1]
+	if isNullish(a) || isNullish(b) {
+		return nil, nil
+	}
+
+	ad, aDate := a.(primitive.DateTime)
+	bd, bDate := b.(primitive.DateTime)
+	switch {
+	case aDate && bDate:
+		return int64(ad) - int64(bd), nil
+	case aDate && isNumber(b):
+		return primitive.DateTime(int64(ad) - int64(math.Round(toFloat(b)))), nil
+	case isNumber(a) && isNumber(b):
+		return arith(a, b,
+			subInt,
+			func(a, b float64) float64 { return a - b }), nil
+	}
+	return nil, fmt.Errorf("can't subtract %T from %T", b, a)
+}
+
+func evalMultiply(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	var product any = int32(1)
+	for _, v := range vals {
+		if isNullish(v) {
+			return nil, nil
+		}
+		if !isNumber(v) {
+			return nil, fmt.Errorf("arguments must be numbers, got %T", v)
+		}
+		product = arith(product, v, mulInt, func(a, b float64) float64 { return a * b })
+	}
+	return product, nil
+}
+
+func evalDivide(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) || isNullish(vals[1]) {
+		return nil, nil
+	}
+	if !isNumber(vals[0]) || !isNumber(vals[1]) {
+		return nil, fmt.Errorf("arguments must be numbers, got %T and %T", vals[0], vals[1])
+	}
+	if toFloat(vals[1]) == 0 {
+		return nil, errors.New("can't divide by zero")
+	}
+	return toFloat(vals[0]) / toFloat(vals[1]), nil
+}
+
+func evalMod(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) || isNullish(vals[1]) {
+		return nil, nil
+	}
+	if !isNumber(vals[0]) || !isNumber(vals[1]) {
+		return nil, fmt.Errorf("arguments must be numbers, got %T and %T", vals[0], vals[1])
+	}
+	if toFloat(vals[1]) == 0 {
+		return nil, errors.New("can't take the remainder of division by zero")
+	}
+	return arith(vals[0], vals[1],
+		func(a, b int64) (int64, bool) { return a % b, true },
+		math.Mod), nil
+}
+
+func evalPow(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) || isNullish(vals[1]) {
+		return nil, nil
+	}
+	if !isNumber(vals[0]) || !isNumber(vals[1]) {
+		return nil, fmt.Errorf("arguments must be numbers, got %T and %T", vals[0], vals[1])
+	}
+	base, exp := toFloat(vals[0]), toFloat(vals[1])
+	if base == 0 && exp < 0 {
+		return nil, errors.New("can't raise 0 to a negative exponent")
+	}
+	bi, baseInt := toInt64(vals[0])
+	ei, expInt := toInt64(vals[1])
+	if baseInt && expInt && ei >= 0 {
+		if n, ok := powInt(bi, ei); ok {
+			_, a32 := vals[0].(int32)
+			_, b32 := vals[1].(int32)
+			return intResult(n, a32 && b32), nil
+		}
+	}
+	return math.Pow(base, exp), nil
+}
+
+// powInt raises base to a non-negative exponent by squaring. It returns false
+// if the result overflows an int64.
+func powInt(base, exp int64) (int64, bool) {
+	n := int64(1)
+	for ok := true; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			if n, ok = mulInt(n, base); !ok {
+				return 0, false
+			}
+		}
+		if exp > 1 {
+			if base, ok = mulInt(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return n, true
+}
+
+func evalRound(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) {
+		return nil, nil
+	}
+	if !isNumber(vals[0]) {
+		return nil, fmt.Errorf("argument must be a number, got %T", vals[0])
+	}
+	place := 0.0
+	if len(vals) == 2 {
+		if !isNumber(vals[1]) {
+			return nil, fmt.Errorf("place must be a number, got %T", vals[1])
+		}
+		place = toFloat(vals[1])
+		if place != math.Trunc(place) || place < -20 || place > 100 {
+			return nil, fmt.Errorf("place must be an integer in [-20, 100], got %v", place)
+		}
+	}
+	if n, ok := toInt64(vals[0]); ok {
+		if place >= 0 {
+			return vals[0], nil
+		}
+		rounded, ok := roundInt(n, int(-place))
+		if !ok {
+			return nil, fmt.Errorf("rounding %d to %v places overflows", n, place)
+		}
+		_, is32 := vals[0].(int32)
+		return intResult(rounded, is32), nil
+	}
+	scale := math.Pow(10, place)
+	return math.RoundToEven(toFloat(vals[0])*scale) / scale, nil
+}
+
+// roundInt rounds n half to even to a multiple of 10^digits. It returns false
+// if the result overflows an int64.
+func roundInt(n int64, digits int) (int64, bool) {
+	unit := pow10(digits)
+	q, r := new(big.Int).QuoRem(big.NewInt(n), unit, new(big.Int))
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	if c := twice.Cmp(unit); c > 0 || c == 0 && q.Bit(0) == 1 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	q.Mul(q, unit)
+	return q.Int64(), q.IsInt64()
+}
+
+func comparison(result func(c int) any) operatorFunc {
+	return func(ev *evaluator, arg any) (any, error) {
+		vals, err := ev.evalArgs(arg, 2, 2)
+		if err != nil {
+			return nil, err
+		}
+		return result(compare(vals[0], vals[1])), nil
+	}
+}
+
+func evalAnd(ev *evaluator, arg any) (any, error) {
+	for _, a := range args(arg) {
+		v, err := ev.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		if !truthy(v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func evalOr(ev *evaluator, arg any) (any, error) {
+	for _, a := range args(arg) {
+		v, err := ev.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func evalNot(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(vals[0]), nil
+}
+
+func evalCond(ev *evaluator, arg any) (any, error) {
+	var ifExpr, thenExpr, elseExpr any
+	if a, ok := arg.(bson.A); ok {
+		if len(a) != 3 {
+			return nil, fmt.Errorf("expected 3 arguments, got %d", len(a))
+		}
+		ifExpr, thenExpr, elseExpr = a[0], a[1], a[2]
+	} else {
+		m, err := namedArgs(arg, "if", "then", "else")
+		if err != nil {
+			return nil, err
+		}
+		ifExpr, thenExpr, elseExpr = m["if"], m["then"], m["else"]
+	}
+
+	cond, err := ev.eval(ifExpr)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return ev.eval(thenExpr)
+	}
+	return ev.eval(elseExpr)
+}
+
+func evalIfNull(ev *evaluator, arg any) (any, error) {
+	a := args(arg)
+	if len(a) < 2 {
+		return nil, fmt.Errorf("expected at least 2 arguments, got %d", len(a))
+	}
+	for _, e := range a[:len(a)-1] {
+		v, err := ev.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		if !isNullish(v) {
+			return v, nil
+		}
+	}
+	return ev.eval(a[len(a)-1])
+}
+
+func evalSwitch(ev *evaluator, arg any) (any, error) {
+	m, err := namedArgs(arg, "branches")
+	if err != nil {
+		return nil, err
+	}
+	branches, ok := m["branches"].(bson.A)
+	if !ok {
+		return nil, errors.New("branches must be an array")
+	}
+	for i, b := range branches {
+		bm, err := namedArgs(b, "case", "then")
+		if err != nil {
+			return nil, fmt.Errorf("invalid branch %d: %w", i, err)
+		}
+		c, err := ev.eval(bm["case"])
+		if err != nil {
+			return nil, err
+		}
+		if truthy(c) {
+			return ev.eval(bm["then"])
+		}
+	}
+	def, ok := m["default"]
+	if !ok {
+		return nil, errors.New("no branch matched and no default was specified")
+	}
+	return ev.eval(def)
+}
+
+func evalConcat(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, v := range vals {
+		switch v := v.(type) {
+		case nil, missingValue:
+			return nil, nil
+		case string:
+			sb.WriteString(v)
+		default:
+			return nil, fmt.Errorf("arguments must be strings, got %T", v)
+		}
+	}
+	return sb.String(), nil
+}
+
+func evalIndexOfBytes(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 4)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) {
+		return nil, nil
+	}
+	s, ok1 := vals[0].(string)
+	sub, ok2 := vals[1].(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("arguments must be strings, got %T and %T", vals[0], vals[1])
+	}
+	start, end := int64(0), int64(len(s))
+	for i, bound := range []*int64{&start, &end} {
+		if len(vals) <= 2+i {
+			break
+		}
+		n, ok := toInt64(vals[2+i])
+		if !ok || n < 0 {
+			return nil, errors.New("start and end must be non-negative integers")
+		}
+		*bound = n
+	}
+	if end > int64(len(s)) {
+		end = int64(len(s))
+	}
+	if start > end {
+		return int32(-1), nil
+	}
+	i := strings.Index(s[start:end], sub)
+	if i < 0 {
+		return int32(-1), nil
+	}
+	return int32(start) + int32(i), nil
+}
+
+func caseFunc(fn func(string) string) operatorFunc {
+	return func(ev *evaluator, arg any) (any, error) {
+		vals, err := ev.evalArgs(arg, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		switch v := vals[0].(type) {
+		case nil, missingValue:
+			return "", nil
+		case string:
+			return fn(v), nil
+		}
+		s, err := toString(vals[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(s), nil
+	}
+}
+
+func evalSplit(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) {
+		return nil, nil
+	}
+	s, ok1 := vals[0].(string)
+	sep, ok2 := vals[1].(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("arguments must be strings, got %T and %T", vals[0], vals[1])
+	}
+	if sep == "" {
+		return nil, errors.New("delimiter must not be empty")
+	}
+	parts := strings.Split(s, sep)
+	a := make(bson.A, len(parts))
+	for i := range parts {
+		a[i] = parts[i]
+	}
+	return a, nil
+}
+
+func evalStrLenCP(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := vals[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string, got %T", vals[0])
+	}
+	return int32(utf8.RuneCountInString(s)), nil
+}
+
+func evalSubstrCP(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 3, 3)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) {
+		return "", nil
+	}
+	s, ok := vals[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string, got %T", vals[0])
+	}
+	start, ok1 := toInt64(vals[1])
+	count, ok2 := toInt64(vals[2])
+	if !ok1 || !ok2 || start < 0 || count < 0 {
+		return nil, errors.New("start and count must be non-negative integers")
+	}
+	runes := []rune(s)
+	if start > int64(len(runes)) {
+		return "", nil
+	}
+	end := start + count
+	if end > int64(len(runes)) {
+		end = int64(len(runes))
+	}
+	return string(runes[start:end]), nil
+}
+
+func evalTrim(ev *evaluator, arg any) (any, error) {
+	m, err := namedArgs(arg, "input")
+	if err != nil {
+		return nil, err
+	}
+	input, err := ev.eval(m["input"])
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(input) {
+		return nil, nil
+	}
+	s, ok := input.(string)
+	if !ok {
+		return nil, fmt.Errorf("input must be a string, got %T", input)
+	}
+	charsExpr, ok := m["chars"]
+	if !ok {
+		return strings.TrimSpace(s), nil
+	}
+	chars, err := ev.eval(charsExpr)
+	if err != nil {
+		return nil, err
+	}
+	cs, ok := chars.(string)
+	if !ok {
+		return nil, fmt.Errorf("chars must be a string, got %T", chars)
+	}
+	return strings.Trim(s, cs), nil
+}
+
+func evalToString(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) {
+		return nil, nil
+	}
+	return toString(vals[0])
+}
+
+func toString(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case primitive.ObjectID:
+		return v.Hex(), nil
+	case primitive.DateTime:
+		return v.Time().UTC().Format("2006-01-02T15:04:05.000Z"), nil
+	}
+	return "", fmt.Errorf("can't convert %T to a string", v)
+}
+
+func evalArray(ev *evaluator, expr any) (bson.A, bool, error) {
+	v, err := ev.eval(expr)
+	if err != nil {
+		return nil, false, err
+	}
+	if isNullish(v) {
+		return nil, true, nil
+	}
+	a, ok := v.(bson.A)
+	if !ok {
+		return nil, false, fmt.Errorf("argument must be an array, got %T", v)
+	}
+	return a, false, nil
+}
+
+func evalArrayElemAt(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) || isNullish(vals[1]) {
+		return nil, nil
+	}
+	a, ok := vals[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("first argument must be an array, got %T", vals[0])
+	}
+	i, ok := toInt64(vals[1])
+	if !ok {
+		return nil, fmt.Errorf("second argument must be an integer, got %T", vals[1])
+	}
+	if i < 0 {
+		i += int64(len(a))
+	}
+	if i < 0 || i >= int64(len(a)) {
+		return missing, nil
+	}
+	return a[i], nil
+}
+
+func evalConcatArrays(ev *evaluator, arg any) (any, error) {
+	res := bson.A{}
+	for _, e := range args(arg) {
+		a, null, err := evalArray(ev, e)
+		if err != nil {
+			return nil, err
+		}
+		if null {
+			return nil, nil
+		}
+		res = append(res, a...)
+	}
+	return res, nil
+}
+
+func evalFilter(ev *evaluator, arg any) (any, error) {
+	m, err := namedArgs(arg, "input", "cond")
+	if err != nil {
+		return nil, err
+	}
+	input, null, err := evalArray(ev, m["input"])
+	if err != nil || null {
+		return nil, err
+	}
+	as, err := asName(m)
+	if err != nil {
+		return nil, err
+	}
+	limit := int64(-1)
+	if l, ok := m["limit"]; ok {
+		lv, err := ev.eval(l)
+		if err != nil {
+			return nil, err
+		}
+		if !isNullish(lv) {
+			n, ok := toInt64(lv)
+			if !ok || n < 1 {
+				return nil, errors.New("limit must be a positive integer")
+			}
+			limit = n
+		}
+	}
+
+	res := bson.A{}
+	for _, elem := range input {
+		if limit >= 0 && int64(len(res)) >= limit {
+			break
+		}
+		c, err := ev.withVars(map[string]any{as: elem}).eval(m["cond"])
+		if err != nil {
+			return nil, err
+		}
+		if truthy(c) {
+			res = append(res, elem)
+		}
+	}
+	return res, nil
+}
+
+func evalMap(ev *evaluator, arg any) (any, error) {
+	m, err := namedArgs(arg, "input", "in")
+	if err != nil {
+		return nil, err
+	}
+	input, null, err := evalArray(ev, m["input"])
+	if err != nil || null {
+		return nil, err
+	}
+	as, err := asName(m)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(bson.A, len(input))
+	for i, elem := range input {
+		v, err := ev.withVars(map[string]any{as: elem}).eval(m["in"])
+		if err != nil {
+			return nil, err
+		}
+		if v == missing {
+			v = nil
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func asName(m map[string]any) (string, error) {
+	as, ok := m["as"]
+	if !ok {
+		return "this", nil
+	}
+	s, ok := as.(string)
+	if !ok || s == "" {
+		return "", errors.New(`"as" must be a non-empty string`)
+	}
+	return s, nil
+}
+
+func evalReduce(ev *evaluator, arg any) (any, error) {
+	m, err := namedArgs(arg, "input", "initialValue", "in")
+	if err != nil {
+		return nil, err
+	}
+	input, null, err := evalArray(ev, m["input"])
+	if err != nil || null {
+		return nil, err
+	}
+	value, err := ev.eval(m["initialValue"])
+	if err != nil {
+		return nil, err
+	}
+	for _, elem := range input {
+		value, err = ev.withVars(map[string]any{"value": value, "this": elem}).eval(m["in"])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func arrayEnd(first bool) operatorFunc {
+	return func(ev *evaluator, arg any) (any, error) {
+		vals, err := ev.evalArgs(arg, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(vals[0]) {
+			return nil, nil
+		}
+		a, ok := vals[0].(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("argument must be an array, got %T", vals[0])
+		}
+		if len(a) == 0 {
+			return missing, nil
+		}
+		if first {
+			return a[0], nil
+		}
+		return a[len(a)-1], nil
+	}
+}
+
+func evalIn(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := vals[1].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("second argument must be an array, got %T", vals[1])
+	}
+	for _, elem := range a {
+		if compare(vals[0], elem) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func evalIsArray(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := vals[0].(bson.A)
+	return ok, nil
+}
+
+func evalSize(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := vals[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("argument must be an array, got %T", vals[0])
+	}
+	return int32(len(a)), nil
+}
+
+// accumulatorInputs returns the values an accumulator expression like $sum
+// operates on: the elements of its argument if it has a single array
+// argument, otherwise the arguments.
+func accumulatorInputs(ev *evaluator, arg any) ([]any, error) {
+	vals, err := ev.evalArgs(arg, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 1 {
+		if a, ok := vals[0].(bson.A); ok {
+			return a, nil
+		}
+	}
+	return vals, nil
+}
+
+func evalSum(ev *evaluator, arg any) (any, error) {
+	vals, err := accumulatorInputs(ev, arg)
+	if err != nil {
+		return nil, err
+	}
+	var sum any = int32(0)
+	for _, v := range vals {
+		if isNumber(v) {
+			sum = arith(sum, v, addInt, func(a, b float64) float64 { return a + b })
+		}
+	}
+	return sum, nil
+}
+
+func evalAvg(ev *evaluator, arg any) (any, error) {
+	vals, err := accumulatorInputs(ev, arg)
+	if err != nil {
+		return nil, err
+	}
+	var sum float64
+	var n int
+	for _, v := range vals {
+		if isNumber(v) {
+			sum += toFloat(v)
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return sum / float64(n), nil
+}
+
+// extremum returns an operator func for $max (sign 1) or $min (sign -1).
+func extremum(sign int) operatorFunc {
+	return func(ev *evaluator, arg any) (any, error) {
+		vals, err := accumulatorInputs(ev, arg)
+		if err != nil {
+			return nil, err
+		}
+		var res any
+		for _, v := range vals {
+			if isNullish(v) {
+				continue
+			}
+			if res == nil || compare(v, res)*sign > 0 {
+				res = v
+			}
+		}
+		return res, nil
+	}
+}
+
+func evalMergeObjects(ev *evaluator, arg any) (any, error) {
+	vals, err := accumulatorInputs(ev, arg)
+	if err != nil {
+		return nil, err
+	}
+	res := bson.D{}
+	for _, v := range vals {
+		if isNullish(v) {
+			continue
+		}
+		d, ok := v.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("arguments must be documents, got %T", v)
+		}
+	elems:
+		for _, e := range d {
+			for i := range res {
+				if res[i].Key == e.Key {
+					res[i].Value = e.Value
+					continue elems
+				}
+			}
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func evalObjectToArray(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(vals[0]) {
+		return nil, nil
+	}
+	d, ok := vals[0].(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a document, got %T", vals[0])
+	}
+	a := make(bson.A, len(d))
+	for i, e := range d {
+		a[i] = bson.D{{Key: "k", Value: e.Key}, {Key: "v", Value: e.Value}}
+	}
+	return a, nil
+}
+
+func evalType(ev *evaluator, arg any) (any, error) {
+	vals, err := ev.evalArgs(arg, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch vals[0].(type) {
+	case missingValue:
+		return "missing", nil
+	case nil, primitive.Null:
+		return "null", nil
+	case float64:
+		return "double", nil
+	case string:
+		return "string", nil
+	case bson.D:
+		return "object", nil
+	case bson.A:
+		return "array", nil
+	case primitive.Binary:
+		return "binData", nil
+	case primitive.Undefined:
+		return "undefined", nil
+	case primitive.ObjectID:
+		return "objectId", nil
+	case bool:
+		return "bool", nil
+	case primitive.DateTime:
+		return "date", nil
+	case primitive.Regex:
+		return "regex", nil
+	case primitive.DBPointer:
+		return "dbPointer", nil
+	case primitive.JavaScript:
+		return "javascript", nil
+	case primitive.Symbol:
+		return "symbol", nil
+	case primitive.CodeWithScope:
+		return "javascriptWithScope", nil
+	case int32:
+		return "int", nil
+	case primitive.Timestamp:
+		return "timestamp", nil
+	case int64:
+		return "long", nil
+	case primitive.Decimal128:
+		return "decimal", nil
+	case primitive.MinKey:
+		return "minKey", nil
+	case primitive.MaxKey:
+		return "maxKey", nil
+	}
+	return nil, fmt.Errorf("unknown type %T", vals[0])
+}
+
+func evalLet(ev *evaluator, arg any) (any, error) {
+	m, err := namedArgs(arg, "vars", "in")
+	if err != nil {
+		return nil, err
+	}
+	varsDoc, ok := m["vars"].(bson.D)
+	if !ok {
+		return nil, errors.New("vars must be a document")
+	}
+	vars := make(map[string]any, len(varsDoc))
+	for _, e := range varsDoc {
+		v, err := ev.eval(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		vars[e.Key] = v
+	}
+	return ev.withVars(vars).eval(m["in"])
+}
@@ -0,0 +1,552 @@
+package eval
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testDoc is the document the operator tests are evaluated against.
+var testDoc = bson.D{
+	{Key: "null", Value: nil},
+	{Key: "i", Value: int32(5)},
+	{Key: "s", Value: "abc"},
+	{Key: "arr", Value: bson.A{int32(1), int32(2), int32(3)}},
+	{Key: "date", Value: primitive.DateTime(1000)},
+}
+
+type evalTest struct {
+	name    string
+	expr    any
+	want    any
+	wantErr bool
+}
+
+func runEvalTests(t *testing.T, tests []evalTest) {
+	t.Helper()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Eval(tc.expr, testDoc)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %#v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v (%T), want %#v (%T)", got, got, tc.want, tc.want)
+			}
+		})
+	}
+}
+
+// op returns the operator expression {name: args}, or {name: [args...]} if
+// there's more than one argument.
+func op(name string, args ...any) bson.D {
+	if len(args) == 1 {
+		return bson.D{{Key: name, Value: args[0]}}
+	}
+	return bson.D{{Key: name, Value: bson.A(args)}}
+}
+
+func mustDecimal(s string) primitive.Decimal128 {
+	d, err := primitive.ParseDecimal128(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestAbs(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "int32", expr: op("$abs", int32(-5)), want: int32(5)},
+		{name: "int64", expr: op("$abs", int64(-5)), want: int64(5)},
+		{name: "double", expr: op("$abs", -1.5), want: 1.5},
+		{name: "min int32 promotes to int64", expr: op("$abs", int32(math.MinInt32)), want: int64(-math.MinInt32)},
+		{name: "min int64 promotes to double", expr: op("$abs", int64(math.MinInt64)), want: math.Exp2(63)},
+		{name: "null", expr: op("$abs", nil), want: nil},
+		{name: "missing", expr: op("$abs", "$x"), want: nil},
+		{name: "string", expr: op("$abs", "$s"), wantErr: true},
+	})
+}
+
+func TestAdd(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "no arguments", expr: op("$add", bson.A{}), want: int32(0)},
+		{name: "int32", expr: op("$add", int32(1), int32(2)), want: int32(3)},
+		{name: "int32 and int64", expr: op("$add", int32(1), int64(2)), want: int64(3)},
+		{name: "int and double", expr: op("$add", int32(1), 0.5), want: 1.5},
+		{name: "int32 overflow", expr: op("$add", int32(math.MaxInt32), int32(1)), want: int64(math.MaxInt32 + 1)},
+		{name: "int64 overflow", expr: op("$add", int64(math.MaxInt64), int32(1)), want: math.Exp2(63)},
+		{name: "negative int64 overflow", expr: op("$add", int64(math.MinInt64), int32(-1)), want: -math.Exp2(63)},
+		{name: "date", expr: op("$add", "$date", int32(500)), want: primitive.DateTime(1500)},
+		{name: "date rounds double", expr: op("$add", 1.6, "$date"), want: primitive.DateTime(1002)},
+		{name: "two dates", expr: op("$add", "$date", "$date"), wantErr: true},
+		{name: "null", expr: op("$add", int32(1), nil), want: nil},
+		{name: "missing", expr: op("$add", int32(1), "$x"), want: nil},
+		{name: "string", expr: op("$add", int32(1), "$s"), wantErr: true},
+	})
+}
+
+func TestSubtract(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "int32", expr: op("$subtract", int32(5), int32(3)), want: int32(2)},
+		{name: "int64", expr: op("$subtract", int64(5), int32(3)), want: int64(2)},
+		{name: "double", expr: op("$subtract", int32(5), 0.5), want: 4.5},
+		{name: "int32 overflow", expr: op("$subtract", int32(math.MinInt32), int32(1)), want: int64(math.MinInt32 - 1)},
+		{name: "int64 overflow", expr: op("$subtract", int64(math.MaxInt64), int32(-1)), want: math.Exp2(63)},
+		{name: "min int64", expr: op("$subtract", int64(0), int64(math.MinInt64)), want: math.Exp2(63)},
+		{name: "min int64 from negative", expr: op("$subtract", int64(-1), int64(math.MinInt64)), want: int64(math.MaxInt64)},
+		{name: "dates", expr: op("$subtract", "$date", primitive.DateTime(400)), want: int64(600)},
+		{name: "date and number", expr: op("$subtract", "$date", int32(400)), want: primitive.DateTime(600)},
+		{name: "number and date", expr: op("$subtract", int32(400), "$date"), wantErr: true},
+		{name: "null", expr: op("$subtract", "$null", int32(1)), want: nil},
+		{name: "missing", expr: op("$subtract", int32(1), "$x"), want: nil},
+		{name: "string", expr: op("$subtract", "$s", int32(1)), wantErr: true},
+		{name: "one argument", expr: op("$subtract", bson.A{int32(1)}), wantErr: true},
+	})
+}
+
+func TestMultiply(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "int32", expr: op("$multiply", int32(2), int32(3)), want: int32(6)},
+		{name: "int64", expr: op("$multiply", int64(2), int32(3)), want: int64(6)},
+		{name: "double", expr: op("$multiply", int32(2), 1.5), want: 3.0},
+		{name: "int32 overflow", expr: op("$multiply", int32(math.MaxInt32), int32(2)), want: int64(math.MaxInt32 * 2)},
+		{name: "int64 overflow", expr: op("$multiply", int64(math.MaxInt64), int32(2)), want: float64(math.MaxInt64) * 2},
+		{name: "min int64 times -1", expr: op("$multiply", int64(math.MinInt64), int32(-1)), want: math.Exp2(63)},
+		{name: "zero", expr: op("$multiply", int64(math.MinInt64), int32(0)), want: int64(0)},
+		{name: "null", expr: op("$multiply", int32(2), nil), want: nil},
+		{name: "missing", expr: op("$multiply", "$x", int32(2)), want: nil},
+		{name: "string", expr: op("$multiply", "$s", int32(2)), wantErr: true},
+	})
+}
+
+func TestDivide(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "ints return double", expr: op("$divide", int32(5), int32(2)), want: 2.5},
+		{name: "exact ints return double", expr: op("$divide", int64(6), int32(3)), want: 2.0},
+		{name: "by zero", expr: op("$divide", int32(1), int32(0)), wantErr: true},
+		{name: "by double zero", expr: op("$divide", int32(1), 0.0), wantErr: true},
+		{name: "null", expr: op("$divide", nil, int32(2)), want: nil},
+		{name: "missing", expr: op("$divide", int32(2), "$x"), want: nil},
+		{name: "string", expr: op("$divide", "$s", int32(2)), wantErr: true},
+	})
+}
+
+func TestMod(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "int32", expr: op("$mod", int32(7), int32(3)), want: int32(1)},
+		{name: "negative dividend", expr: op("$mod", int32(-7), int32(3)), want: int32(-1)},
+		{name: "int32 and int64", expr: op("$mod", int32(7), int64(3)), want: int64(1)},
+		{name: "double", expr: op("$mod", 7.5, int32(2)), want: 1.5},
+		{name: "min int64 by -1", expr: op("$mod", int64(math.MinInt64), int32(-1)), want: int64(0)},
+		{name: "by zero", expr: op("$mod", int32(7), int32(0)), wantErr: true},
+		{name: "null", expr: op("$mod", nil, int32(3)), want: nil},
+		{name: "missing", expr: op("$mod", int32(7), "$x"), want: nil},
+		{name: "string", expr: op("$mod", "$s", int32(3)), wantErr: true},
+	})
+}
+
+func TestPow(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "int32", expr: op("$pow", int32(2), int32(10)), want: int32(1024)},
+		{name: "int32 overflow", expr: op("$pow", int32(2), int32(40)), want: int64(1 << 40)},
+		{name: "int64", expr: op("$pow", int64(3), int32(2)), want: int64(9)},
+		{name: "exact int64", expr: op("$pow", int64(3), int32(39)), want: int64(4052555153018976267)},
+		{name: "min int64", expr: op("$pow", int64(-2), int32(63)), want: int64(math.MinInt64)},
+		{name: "int64 overflow", expr: op("$pow", int64(2), int32(63)), want: math.Exp2(63)},
+		{name: "negative exponent", expr: op("$pow", int32(2), int32(-1)), want: 0.5},
+		{name: "double", expr: op("$pow", 4.0, 0.5), want: 2.0},
+		{name: "zero to negative exponent", expr: op("$pow", int32(0), int32(-1)), wantErr: true},
+		{name: "null", expr: op("$pow", nil, int32(2)), want: nil},
+		{name: "missing", expr: op("$pow", int32(2), "$x"), want: nil},
+		{name: "string", expr: op("$pow", "$s", int32(2)), wantErr: true},
+	})
+}
+
+func TestCeilFloorSqrt(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "$ceil double", expr: op("$ceil", 1.2), want: 2.0},
+		{name: "$ceil negative double", expr: op("$ceil", -1.2), want: -1.0},
+		{name: "$ceil int32", expr: op("$ceil", int32(3)), want: int32(3)},
+		{name: "$ceil null", expr: op("$ceil", nil), want: nil},
+		{name: "$floor double", expr: op("$floor", 1.8), want: 1.0},
+		{name: "$floor int64", expr: op("$floor", int64(3)), want: int64(3)},
+		{name: "$floor missing", expr: op("$floor", "$x"), want: nil},
+		{name: "$floor string", expr: op("$floor", "$s"), wantErr: true},
+		{name: "$sqrt int", expr: op("$sqrt", int32(16)), want: 4.0},
+		{name: "$sqrt null", expr: op("$sqrt", "$null"), want: nil},
+	})
+}
+
+func TestRound(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "half to even down", expr: op("$round", 2.5), want: 2.0},
+		{name: "half to even up", expr: op("$round", 3.5), want: 4.0},
+		{name: "double places", expr: op("$round", 1.2345, int32(2)), want: 1.23},
+		{name: "double negative places", expr: op("$round", 1234.5, int32(-2)), want: 1200.0},
+		{name: "int32", expr: op("$round", int32(5)), want: int32(5)},
+		{name: "int64 positive places", expr: op("$round", int64(5), int32(2)), want: int64(5)},
+		{name: "int32 negative places", expr: op("$round", int32(1234), int32(-2)), want: int32(1200)},
+		{name: "int32 half to even down", expr: op("$round", int32(1250), int32(-2)), want: int32(1200)},
+		{name: "int32 half to even up", expr: op("$round", int32(1350), int32(-2)), want: int32(1400)},
+		{name: "negative int32", expr: op("$round", int32(-1260), int32(-2)), want: int32(-1300)},
+		{name: "int64 negative places", expr: op("$round", int64(98765), int32(-3)), want: int64(99000)},
+		{name: "all digits", expr: op("$round", int64(123), int32(-20)), want: int64(0)},
+		{name: "int32 overflow", expr: op("$round", int32(math.MaxInt32), int32(-1)), want: int64(2147483650)},
+		{name: "int64 overflow", expr: op("$round", int64(math.MaxInt64), int32(-1)), wantErr: true},
+		{name: "fractional place", expr: op("$round", 1.5, 0.5), wantErr: true},
+		{name: "place out of range", expr: op("$round", 1.5, int32(-21)), wantErr: true},
+		{name: "null", expr: op("$round", nil, int32(1)), want: nil},
+		{name: "missing", expr: op("$round", "$x"), want: nil},
+		{name: "string", expr: op("$round", "$s"), wantErr: true},
+	})
+}
+
+func TestComparisonOperators(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "$cmp less", expr: op("$cmp", int32(1), int32(2)), want: int32(-1)},
+		{name: "$cmp equal across types", expr: op("$cmp", int32(1), 1.0), want: int32(0)},
+		{name: "$cmp greater", expr: op("$cmp", "b", "a"), want: int32(1)},
+		{name: "$eq int32 and int64", expr: op("$eq", int32(1), int64(1)), want: true},
+		{name: "$eq decimal and int", expr: op("$eq", mustDecimal("1.0"), int32(1)), want: true},
+		{name: "$eq missing and null", expr: op("$eq", "$x", nil), want: false},
+		{name: "$eq null and null", expr: op("$eq", "$null", nil), want: true},
+		{name: "$eq arrays", expr: op("$eq", "$arr", bson.A{int32(1), int64(2), 3.0}), want: true},
+		{name: "$ne", expr: op("$ne", "$s", "abc"), want: false},
+		{name: "$gt string and number", expr: op("$gt", "$s", int32(5)), want: true},
+		{name: "$gt decimal", expr: op("$gt", mustDecimal("5.5"), "$i"), want: true},
+		{name: "$gte", expr: op("$gte", "$i", int64(5)), want: true},
+		{name: "$lt null and number", expr: op("$lt", nil, int32(0)), want: true},
+		{name: "$lt decimal and double", expr: op("$lt", mustDecimal("0.1"), 0.1), want: true},
+		{name: "$lte", expr: op("$lte", int32(6), "$i"), want: false},
+		{name: "one argument", expr: op("$eq", bson.A{int32(1)}), wantErr: true},
+	})
+}
+
+func TestBooleanOperators(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "$and no arguments", expr: op("$and", bson.A{}), want: true},
+		{name: "$and true", expr: op("$and", true, int32(1), "$s"), want: true},
+		{name: "$and zero", expr: op("$and", true, int64(0)), want: false},
+		{name: "$and null", expr: op("$and", true, "$null"), want: false},
+		{name: "$and missing", expr: op("$and", true, "$x"), want: false},
+		{name: "$or no arguments", expr: op("$or", bson.A{}), want: false},
+		{name: "$or", expr: op("$or", nil, 0.0, "$arr"), want: true},
+		{name: "$or false", expr: op("$or", false, "$x"), want: false},
+		{name: "$not", expr: op("$not", bson.A{int32(0)}), want: true},
+		{name: "$not missing", expr: op("$not", "$x"), want: true},
+		{name: "$not string", expr: op("$not", "$s"), want: false},
+	})
+}
+
+func TestCond(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "array true", expr: op("$cond", true, "yes", "no"), want: "yes"},
+		{name: "array false", expr: op("$cond", int32(0), "yes", "no"), want: "no"},
+		{name: "document", expr: op("$cond", bson.D{
+			{Key: "if", Value: op("$gt", "$i", int32(3))},
+			{Key: "then", Value: "$s"},
+			{Key: "else", Value: nil},
+		}), want: "abc"},
+		{name: "null condition", expr: op("$cond", "$null", "yes", "no"), want: "no"},
+		{name: "missing condition", expr: op("$cond", "$x", "yes", "no"), want: "no"},
+		{name: "missing result", expr: op("$cond", true, "$x", "no"), want: nil},
+		{name: "wrong argument count", expr: op("$cond", true, "yes"), wantErr: true},
+		{name: "missing else", expr: op("$cond", bson.D{{Key: "if", Value: true}, {Key: "then", Value: 1}}), wantErr: true},
+	})
+}
+
+func TestIfNull(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "not null", expr: op("$ifNull", "$i", "default"), want: int32(5)},
+		{name: "zero isn't null", expr: op("$ifNull", int32(0), "default"), want: int32(0)},
+		{name: "null", expr: op("$ifNull", "$null", "default"), want: "default"},
+		{name: "missing", expr: op("$ifNull", "$x", "default"), want: "default"},
+		{name: "first non-null", expr: op("$ifNull", "$x", "$null", "$s", "default"), want: "abc"},
+		{name: "null default", expr: op("$ifNull", "$x", nil), want: nil},
+		{name: "one argument", expr: op("$ifNull", bson.A{"$x"}), wantErr: true},
+	})
+}
+
+func TestSwitch(t *testing.T) {
+	branches := bson.A{
+		bson.D{{Key: "case", Value: op("$lt", "$i", int32(0))}, {Key: "then", Value: "negative"}},
+		bson.D{{Key: "case", Value: op("$gt", "$i", int32(0))}, {Key: "then", Value: "positive"}},
+	}
+	runEvalTests(t, []evalTest{
+		{name: "matching branch", expr: op("$switch", bson.D{{Key: "branches", Value: branches}}), want: "positive"},
+		{name: "default", expr: op("$switch", bson.D{
+			{Key: "branches", Value: branches[:1]},
+			{Key: "default", Value: "other"},
+		}), want: "other"},
+		{name: "null case", expr: op("$switch", bson.D{
+			{Key: "branches", Value: bson.A{bson.D{{Key: "case", Value: "$null"}, {Key: "then", Value: "null"}}}},
+			{Key: "default", Value: "other"},
+		}), want: "other"},
+		{name: "no match without default", expr: op("$switch", bson.D{{Key: "branches", Value: branches[:1]}}), wantErr: true},
+		{name: "invalid branch", expr: op("$switch", bson.D{{Key: "branches", Value: bson.A{bson.D{{Key: "case", Value: true}}}}}), wantErr: true},
+	})
+}
+
+func TestStringOperators(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "$concat", expr: op("$concat", "$s", "-", "def"), want: "abc-def"},
+		{name: "$concat no arguments", expr: op("$concat", bson.A{}), want: ""},
+		{name: "$concat null", expr: op("$concat", "$s", nil), want: nil},
+		{name: "$concat missing", expr: op("$concat", "$x", "$s"), want: nil},
+		{name: "$concat number", expr: op("$concat", "$s", "$i"), wantErr: true},
+
+		{name: "$indexOfBytes", expr: op("$indexOfBytes", "abcabc", "c"), want: int32(2)},
+		{name: "$indexOfBytes start", expr: op("$indexOfBytes", "abcabc", "c", int32(3)), want: int32(5)},
+		{name: "$indexOfBytes end", expr: op("$indexOfBytes", "abcabc", "c", int32(3), int64(5)), want: int32(-1)},
+		{name: "$indexOfBytes start past end", expr: op("$indexOfBytes", "$s", "", int32(4)), want: int32(-1)},
+		{name: "$indexOfBytes not found", expr: op("$indexOfBytes", "$s", "d"), want: int32(-1)},
+		{name: "$indexOfBytes null", expr: op("$indexOfBytes", "$null", "a"), want: nil},
+		{name: "$indexOfBytes missing", expr: op("$indexOfBytes", "$x", "a"), want: nil},
+		{name: "$indexOfBytes negative start", expr: op("$indexOfBytes", "$s", "a", int32(-1)), wantErr: true},
+		{name: "$indexOfBytes number", expr: op("$indexOfBytes", "$s", "$i"), wantErr: true},
+
+		{name: "$split", expr: op("$split", "a,b,,c", ","), want: bson.A{"a", "b", "", "c"}},
+		{name: "$split no separator", expr: op("$split", "$s", ","), want: bson.A{"abc"}},
+		{name: "$split null", expr: op("$split", "$null", ","), want: nil},
+		{name: "$split missing", expr: op("$split", "$x", ","), want: nil},
+		{name: "$split empty separator", expr: op("$split", "$s", ""), wantErr: true},
+		{name: "$split number", expr: op("$split", "$i", ","), wantErr: true},
+
+		{name: "$strLenCP", expr: op("$strLenCP", "héllo"), want: int32(5)},
+		{name: "$strLenCP empty", expr: op("$strLenCP", ""), want: int32(0)},
+		{name: "$strLenCP null", expr: op("$strLenCP", "$null"), wantErr: true},
+		{name: "$strLenCP missing", expr: op("$strLenCP", "$x"), wantErr: true},
+
+		{name: "$substrCP", expr: op("$substrCP", "héllo", int32(1), int32(3)), want: "éll"},
+		{name: "$substrCP past end", expr: op("$substrCP", "$s", int32(1), int64(10)), want: "bc"},
+		{name: "$substrCP start past end", expr: op("$substrCP", "$s", int32(5), int32(1)), want: ""},
+		{name: "$substrCP null", expr: op("$substrCP", "$null", int32(0), int32(1)), want: ""},
+		{name: "$substrCP missing", expr: op("$substrCP", "$x", int32(0), int32(1)), want: ""},
+		{name: "$substrCP negative start", expr: op("$substrCP", "$s", int32(-1), int32(1)), wantErr: true},
+		{name: "$substrCP double count", expr: op("$substrCP", "$s", int32(0), 1.5), wantErr: true},
+
+		{name: "$toLower", expr: op("$toLower", "AbC"), want: "abc"},
+		{name: "$toLower number", expr: op("$toLower", "$i"), want: "5"},
+		{name: "$toLower null", expr: op("$toLower", "$null"), want: ""},
+		{name: "$toLower missing", expr: op("$toLower", "$x"), want: ""},
+		{name: "$toUpper", expr: op("$toUpper", "$s"), want: "ABC"},
+		{name: "$toUpper array", expr: op("$toUpper", bson.A{"$arr"}), wantErr: true},
+
+		{name: "$toString int32", expr: op("$toString", "$i"), want: "5"},
+		{name: "$toString int64", expr: op("$toString", int64(-7)), want: "-7"},
+		{name: "$toString double", expr: op("$toString", 1.5), want: "1.5"},
+		{name: "$toString bool", expr: op("$toString", true), want: "true"},
+		{name: "$toString date", expr: op("$toString", "$date"), want: "1970-01-01T00:00:01.000Z"},
+		{name: "$toString null", expr: op("$toString", "$null"), want: nil},
+		{name: "$toString missing", expr: op("$toString", "$x"), want: nil},
+		{name: "$toString document", expr: op("$toString", bson.A{bson.D{{Key: "a", Value: 1}}}), wantErr: true},
+
+		{name: "$trim whitespace", expr: op("$trim", bson.D{{Key: "input", Value: "  abc \n"}}), want: "abc"},
+		{name: "$trim chars", expr: op("$trim", bson.D{{Key: "input", Value: "xxaxbxx"}, {Key: "chars", Value: "x"}}), want: "axb"},
+		{name: "$trim null", expr: op("$trim", bson.D{{Key: "input", Value: "$null"}}), want: nil},
+		{name: "$trim missing", expr: op("$trim", bson.D{{Key: "input", Value: "$x"}}), want: nil},
+		{name: "$trim number", expr: op("$trim", bson.D{{Key: "input", Value: "$i"}}), wantErr: true},
+		{name: "$trim no input", expr: op("$trim", bson.D{{Key: "chars", Value: "x"}}), wantErr: true},
+	})
+}
+
+func TestArrayOperators(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "$arrayElemAt", expr: op("$arrayElemAt", "$arr", int32(1)), want: int32(2)},
+		{name: "$arrayElemAt negative index", expr: op("$arrayElemAt", "$arr", int64(-1)), want: int32(3)},
+		{name: "$arrayElemAt out of range", expr: op("$arrayElemAt", "$arr", int32(3)), want: nil},
+		{name: "$arrayElemAt null", expr: op("$arrayElemAt", "$null", int32(0)), want: nil},
+		{name: "$arrayElemAt missing index", expr: op("$arrayElemAt", "$arr", "$x"), want: nil},
+		{name: "$arrayElemAt double index", expr: op("$arrayElemAt", "$arr", 1.5), wantErr: true},
+		{name: "$arrayElemAt not an array", expr: op("$arrayElemAt", "$s", int32(0)), wantErr: true},
+
+		{name: "$concatArrays", expr: op("$concatArrays", "$arr", bson.A{"a"}), want: bson.A{int32(1), int32(2), int32(3), "a"}},
+		{name: "$concatArrays null", expr: op("$concatArrays", "$arr", "$null"), want: nil},
+		{name: "$concatArrays missing", expr: op("$concatArrays", "$x", "$arr"), want: nil},
+		{name: "$concatArrays not an array", expr: op("$concatArrays", "$arr", "$s"), wantErr: true},
+
+		{name: "$filter", expr: op("$filter", bson.D{
+			{Key: "input", Value: "$arr"},
+			{Key: "cond", Value: op("$gte", "$$this", int32(2))},
+		}), want: bson.A{int32(2), int32(3)}},
+		{name: "$filter as and limit", expr: op("$filter", bson.D{
+			{Key: "input", Value: "$arr"},
+			{Key: "as", Value: "n"},
+			{Key: "cond", Value: op("$gte", "$$n", int32(1))},
+			{Key: "limit", Value: int32(2)},
+		}), want: bson.A{int32(1), int32(2)}},
+		{name: "$filter null limit", expr: op("$filter", bson.D{
+			{Key: "input", Value: "$arr"},
+			{Key: "cond", Value: true},
+			{Key: "limit", Value: "$x"},
+		}), want: bson.A{int32(1), int32(2), int32(3)}},
+		{name: "$filter zero limit", expr: op("$filter", bson.D{
+			{Key: "input", Value: "$arr"},
+			{Key: "cond", Value: true},
+			{Key: "limit", Value: int32(0)},
+		}), wantErr: true},
+		{name: "$filter null", expr: op("$filter", bson.D{{Key: "input", Value: "$null"}, {Key: "cond", Value: true}}), want: nil},
+		{name: "$filter missing", expr: op("$filter", bson.D{{Key: "input", Value: "$x"}, {Key: "cond", Value: true}}), want: nil},
+		{name: "$filter no cond", expr: op("$filter", bson.D{{Key: "input", Value: "$arr"}}), wantErr: true},
+
+		{name: "$first", expr: op("$first", "$arr"), want: int32(1)},
+		{name: "$first empty", expr: op("$first", bson.A{bson.A{}}), want: nil},
+		{name: "$first null", expr: op("$first", "$null"), want: nil},
+		{name: "$first missing", expr: op("$first", "$x"), want: nil},
+		{name: "$first not an array", expr: op("$first", "$s"), wantErr: true},
+		{name: "$last", expr: op("$last", "$arr"), want: int32(3)},
+
+		{name: "$in", expr: op("$in", int64(2), "$arr"), want: true},
+		{name: "$in double", expr: op("$in", 2.0, "$arr"), want: true},
+		{name: "$in not found", expr: op("$in", "$s", "$arr"), want: false},
+		{name: "$in missing", expr: op("$in", "$x", bson.A{nil}), want: false},
+		{name: "$in not an array", expr: op("$in", int32(1), "$x"), wantErr: true},
+
+		{name: "$isArray", expr: op("$isArray", bson.A{"$arr"}), want: true},
+		{name: "$isArray string", expr: op("$isArray", "$s"), want: false},
+		{name: "$isArray missing", expr: op("$isArray", "$x"), want: false},
+
+		{name: "$map", expr: op("$map", bson.D{
+			{Key: "input", Value: "$arr"},
+			{Key: "in", Value: op("$multiply", "$$this", int32(2))},
+		}), want: bson.A{int32(2), int32(4), int32(6)}},
+		{name: "$map missing results", expr: op("$map", bson.D{
+			{Key: "input", Value: "$arr"},
+			{Key: "as", Value: "n"},
+			{Key: "in", Value: "$x"},
+		}), want: bson.A{nil, nil, nil}},
+		{name: "$map null", expr: op("$map", bson.D{{Key: "input", Value: "$null"}, {Key: "in", Value: 1}}), want: nil},
+		{name: "$map empty as", expr: op("$map", bson.D{{Key: "input", Value: "$arr"}, {Key: "as", Value: ""}, {Key: "in", Value: 1}}), wantErr: true},
+
+		{name: "$reduce", expr: op("$reduce", bson.D{
+			{Key: "input", Value: "$arr"},
+			{Key: "initialValue", Value: int32(0)},
+			{Key: "in", Value: op("$add", "$$value", "$$this")},
+		}), want: int32(6)},
+		{name: "$reduce empty", expr: op("$reduce", bson.D{
+			{Key: "input", Value: bson.A{}},
+			{Key: "initialValue", Value: "init"},
+			{Key: "in", Value: "$$this"},
+		}), want: "init"},
+		{name: "$reduce missing", expr: op("$reduce", bson.D{
+			{Key: "input", Value: "$x"},
+			{Key: "initialValue", Value: int32(0)},
+			{Key: "in", Value: "$$this"},
+		}), want: nil},
+
+		{name: "$size", expr: op("$size", "$arr"), want: int32(3)},
+		{name: "$size null", expr: op("$size", "$null"), wantErr: true},
+		{name: "$size missing", expr: op("$size", "$x"), wantErr: true},
+	})
+}
+
+func TestAccumulatorOperators(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "$sum array", expr: op("$sum", "$arr"), want: int32(6)},
+		{name: "$sum arguments", expr: op("$sum", int32(1), int64(2), "$s"), want: int64(3)},
+		{name: "$sum double", expr: op("$sum", int32(1), 0.5), want: 1.5},
+		{name: "$sum no numbers", expr: op("$sum", "$s", "$null", "$x"), want: int32(0)},
+		{name: "$sum int32 overflow", expr: op("$sum", int32(math.MaxInt32), int32(1)), want: int64(math.MaxInt32 + 1)},
+		{name: "$sum int64 overflow", expr: op("$sum", int64(math.MaxInt64), int32(1)), want: math.Exp2(63)},
+		{name: "$sum missing", expr: op("$sum", "$x"), want: int32(0)},
+
+		{name: "$avg array", expr: op("$avg", "$arr"), want: 2.0},
+		{name: "$avg ignores non-numbers", expr: op("$avg", int32(1), "$s", nil, int64(2)), want: 1.5},
+		{name: "$avg no numbers", expr: op("$avg", "$s", "$x"), want: nil},
+		{name: "$avg missing", expr: op("$avg", "$x"), want: nil},
+
+		{name: "$max array", expr: op("$max", "$arr"), want: int32(3)},
+		{name: "$max mixed types", expr: op("$max", int32(1), "$s", 2.5), want: "abc"},
+		{name: "$max mixed numbers", expr: op("$max", int64(2), 2.5, int32(1)), want: 2.5},
+		{name: "$max ignores null", expr: op("$max", nil, int32(-1), "$x"), want: int32(-1)},
+		{name: "$max only null", expr: op("$max", nil, "$x"), want: nil},
+		{name: "$min array", expr: op("$min", "$arr"), want: int32(1)},
+		{name: "$min mixed types", expr: op("$min", "$s", int64(3), "$null"), want: int64(3)},
+	})
+}
+
+func TestMergeObjects(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "later fields win", expr: op("$mergeObjects",
+			bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(2)}},
+			bson.D{{Key: "b", Value: int32(3)}, {Key: "c", Value: int32(4)}}),
+			want: bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(3)}, {Key: "c", Value: int32(4)}}},
+		{name: "ignores null and missing", expr: op("$mergeObjects", "$null", bson.D{{Key: "a", Value: "$s"}}, "$x"),
+			want: bson.D{{Key: "a", Value: "abc"}}},
+		{name: "only null", expr: op("$mergeObjects", "$null"), want: bson.D{}},
+		{name: "not a document", expr: op("$mergeObjects", "$s"), wantErr: true},
+	})
+}
+
+func TestObjectToArray(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "document", expr: op("$objectToArray", bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: "$s"}}),
+			want: bson.A{
+				bson.D{{Key: "k", Value: "a"}, {Key: "v", Value: int32(1)}},
+				bson.D{{Key: "k", Value: "b"}, {Key: "v", Value: "abc"}},
+			}},
+		{name: "empty document", expr: op("$objectToArray", bson.A{bson.D{}}), want: bson.A{}},
+		{name: "null", expr: op("$objectToArray", "$null"), want: nil},
+		{name: "missing", expr: op("$objectToArray", "$x"), want: nil},
+		{name: "not a document", expr: op("$objectToArray", "$s"), wantErr: true},
+	})
+}
+
+func TestType(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "missing", expr: op("$type", "$x"), want: "missing"},
+		{name: "null", expr: op("$type", "$null"), want: "null"},
+		{name: "int", expr: op("$type", "$i"), want: "int"},
+		{name: "long", expr: op("$type", int64(1)), want: "long"},
+		{name: "double", expr: op("$type", 1.5), want: "double"},
+		{name: "decimal", expr: op("$type", mustDecimal("1.5")), want: "decimal"},
+		{name: "string", expr: op("$type", "$s"), want: "string"},
+		{name: "array", expr: op("$type", bson.A{"$arr"}), want: "array"},
+		{name: "object", expr: op("$type", bson.A{bson.D{}}), want: "object"},
+		{name: "bool", expr: op("$type", false), want: "bool"},
+		{name: "date", expr: op("$type", "$date"), want: "date"},
+		{name: "objectId", expr: op("$type", primitive.NewObjectID()), want: "objectId"},
+	})
+}
+
+func TestLet(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "variables", expr: op("$let", bson.D{
+			{Key: "vars", Value: bson.D{{Key: "a", Value: "$i"}, {Key: "b", Value: int32(2)}}},
+			{Key: "in", Value: op("$multiply", "$$a", "$$b")},
+		}), want: int32(10)},
+		{name: "shadows outer variable", expr: op("$let", bson.D{
+			{Key: "vars", Value: bson.D{{Key: "CURRENT", Value: bson.D{{Key: "i", Value: int32(7)}}}}},
+			{Key: "in", Value: "$i"},
+		}), want: int32(7)},
+		{name: "missing variable value", expr: op("$let", bson.D{
+			{Key: "vars", Value: bson.D{{Key: "a", Value: "$x"}}},
+			{Key: "in", Value: op("$ifNull", "$$a", "default")},
+		}), want: "default"},
+		{name: "undefined variable", expr: op("$let", bson.D{
+			{Key: "vars", Value: bson.D{}},
+			{Key: "in", Value: "$$a"},
+		}), wantErr: true},
+		{name: "vars not a document", expr: op("$let", bson.D{
+			{Key: "vars", Value: "a"},
+			{Key: "in", Value: int32(1)},
+		}), wantErr: true},
+	})
+}
+
+func TestLiteral(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "field path", expr: op("$literal", "$s"), want: "$s"},
+		{name: "operator", expr: op("$literal", bson.D{{Key: "$add", Value: bson.A{int32(1), int32(2)}}}),
+			want: bson.D{{Key: "$add", Value: bson.A{int32(1), int32(2)}}}},
+	})
+}
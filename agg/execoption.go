@@ -0,0 +1,93 @@
+package agg
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExecOption configures the aggregate command run by an execution helper,
+// like AggregateWith and Paginator.
+type ExecOption func(opts *options.AggregateOptions)
+
+// WithAllowDiskUse allows stages like $group and $sort to write temporary
+// files when they exceed their memory limit.
+func WithAllowDiskUse(allow bool) ExecOption {
+	return func(opts *options.AggregateOptions) {
+		opts.SetAllowDiskUse(allow)
+	}
+}
+
+// WithBatchSize sets the number of documents returned in each cursor batch.
+func WithBatchSize(n int32) ExecOption {
+	return func(opts *options.AggregateOptions) {
+		opts.SetBatchSize(n)
+	}
+}
+
+// WithMaxTime sets the maximum time the server spends running the pipeline.
+func WithMaxTime(d time.Duration) ExecOption {
+	return func(opts *options.AggregateOptions) {
+		opts.SetMaxTime(d)
+	}
+}
+
+// WithHint sets the index used by the pipeline, as an index name or an index
+// key document.
+func WithHint(hint any) ExecOption {
+	return func(opts *options.AggregateOptions) {
+		opts.SetHint(hint)
+	}
+}
+
+// WithLet declares variables that can be referenced as "$$<name>" anywhere in
+// the pipeline.
+func WithLet(vars ...FieldExpr) ExecOption {
+	let := fieldExprsToD(vars)
+	return func(opts *options.AggregateOptions) {
+		opts.SetLet(cloneD(let))
+	}
+}
+
+// WithCollation sets the collation used by the pipeline.
+func WithCollation(collation *options.Collation) ExecOption {
+	return func(opts *options.AggregateOptions) {
+		opts.SetCollation(collation)
+	}
+}
+
+// AggregateOptions returns driver aggregate options with the given options
+// applied in order, for use with Collection.Aggregate.
+func AggregateOptions(opts ...ExecOption) *options.AggregateOptions {
+	ao := options.Aggregate()
+	for _, opt := range opts {
+		opt(ao)
+	}
+	return ao
+}
+
+// AggregateWith is like Aggregate, but takes ExecOptions. For example:
+//
+//	cursor, err := agg.AggregateWith(ctx, coll, pipeline,
+//		agg.WithAllowDiskUse(true),
+//		agg.WithMaxTime(30*time.Second))
+func AggregateWith(
+	ctx context.Context,
+	coll *mongo.Collection,
+	pipeline Pipeline,
+	opts ...ExecOption,
+) (*mongo.Cursor, error) {
+	return Aggregate(ctx, coll, pipeline, AggregateOptions(opts...))
+}
+
+// execOptions returns the driver options for a helper's Collation and Exec
+// fields. Options in exec take precedence.
+func execOptions(collation *options.Collation, opts []ExecOption) []*options.AggregateOptions {
+	ao := aggregateCollation(collation)
+	if len(opts) > 0 {
+		ao = append(ao, AggregateOptions(opts...))
+	}
+	return ao
+}
@@ -0,0 +1,64 @@
+package agg
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MarshalExtJSON returns the pipeline as a JSON array of stages in MongoDB
+// Extended JSON. If canonical is false, it uses relaxed Extended JSON, which
+// is easier to read but doesn't preserve numeric types. If indent is true, the
+// output is indented with two spaces, which is handy for logging.
+func (p Pipeline) MarshalExtJSON(canonical, indent bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, s := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := bson.MarshalExtJSON(s, canonical, false)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte(']')
+	return indentJSON(buf.Bytes(), indent)
+}
+
+// MarshalExtJSON returns the operator as a document in MongoDB Extended JSON.
+// See Pipeline.MarshalExtJSON.
+func (o Operator) MarshalExtJSON(canonical, indent bool) ([]byte, error) {
+	return marshalDocExtJSON(bson.D(o), canonical, indent)
+}
+
+// MarshalStageExtJSON returns the stage as a document in MongoDB Extended
+// JSON. Stage is an alias of bson.D, so this can't be a method. See
+// Pipeline.MarshalExtJSON.
+func MarshalStageExtJSON(s Stage, canonical, indent bool) ([]byte, error) {
+	return marshalDocExtJSON(s, canonical, indent)
+}
+
+func marshalDocExtJSON(d bson.D, canonical, indent bool) ([]byte, error) {
+	if d == nil {
+		d = bson.D{}
+	}
+	b, err := bson.MarshalExtJSON(d, canonical, false)
+	if err != nil {
+		return nil, err
+	}
+	return indentJSON(b, indent)
+}
+
+func indentJSON(b []byte, indent bool) ([]byte, error) {
+	if !indent {
+		return b, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,71 @@
+package agg
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// DecodeFacets decodes the output document of a $facet stage into a T, which
+// must be a struct. Each facet is decoded into the struct field with the
+// matching "bson" struct tag name. Slice (and interface) fields get all
+// results of the facet's pipeline; any other field type gets the single
+// result of a facet that produces at most one document, like a $count.
+//
+// For example:
+//
+//	type results struct {
+//		Items []Item `bson:"items"`
+//		Total struct {
+//			N int `bson:"n"`
+//		} `bson:"total"`
+//	}
+//	pipeline := agg.Pipeline{
+//		agg.Facet(
+//			agg.Field("items", agg.Pipeline{agg.Sort(agg.SortAscending("_id"))}),
+//			agg.Field("total", agg.Pipeline{agg.Count("n")}),
+//		),
+//	}
+//	...
+//	res, err := agg.DecodeFacets[results](cursor.Current)
+func DecodeFacets[T any](raw bson.Raw) (T, error) {
+	var t T
+	fields, err := structFields(&t)
+	if err != nil {
+		return t, err
+	}
+
+	for _, f := range fields {
+		rv, err := raw.LookupErr(f.name)
+		if err != nil {
+			continue
+		}
+		if rv.Type != bsontype.Array {
+			return t, fmt.Errorf("facet %q must be an array, got BSON type %s", f.name, rv.Type)
+		}
+
+		switch f.value.Kind() {
+		case reflect.Slice, reflect.Interface:
+			err = rv.Unmarshal(f.value.Addr().Interface())
+		default:
+			var values []bson.RawValue
+			values, err = rv.Array().Values()
+			if err != nil {
+				break
+			}
+			switch len(values) {
+			case 0:
+			case 1:
+				err = values[0].Unmarshal(f.value.Addr().Interface())
+			default:
+				err = fmt.Errorf("got %d results, but field type %s can only hold 1", len(values), f.value.Type())
+			}
+		}
+		if err != nil {
+			return t, fmt.Errorf("error decoding facet %q: %w", f.name, err)
+		}
+	}
+	return t, nil
+}
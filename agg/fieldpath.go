@@ -0,0 +1,36 @@
+package agg
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// GetFieldPath returns the value at the path field.subfields[0]... in the
+// current document by chaining $getField, so field names can contain '.' or
+// start with '$'. For example, GetFieldPath("prices", "usd.retail") gets the
+// "usd.retail" field of the "prices" subdocument, which the field path
+// "$prices.usd.retail" can't express.
+func GetFieldPath(field string, subfields ...string) Operator {
+	op := GetField(nil, stringLiteral(field))
+	for _, f := range subfields {
+		op = GetField(op, stringLiteral(f))
+	}
+	return op
+}
+
+// SetFieldPath returns a copy of the current document with the value at the
+// path field.subfields[0]... set to valueExpr, by chaining $setField and
+// $getField. Missing subdocuments along the path are created. Use the result
+// with a $replaceWith stage to update documents.
+func SetFieldPath(valueExpr any, field string, subfields ...string) Operator {
+	return setFieldPath("$$CURRENT", valueExpr, append([]string{field}, subfields...))
+}
+
+func setFieldPath(inputExpr, valueExpr any, path []string) Operator {
+	field := stringLiteral(path[0])
+	if len(path) == 1 {
+		return SetField(inputExpr, field, valueExpr)
+	}
+
+	// Merge with an empty document so a missing or null subdocument is
+	// created instead of making $setField fail.
+	sub := IfNull(GetField(inputExpr, field), bson.D{})
+	return SetField(inputExpr, field, setFieldPath(sub, valueExpr, path[1:]))
+}
@@ -0,0 +1,81 @@
+//go:build integration
+
+package agg_test
+
+import (
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// gen generates a field value for the document at index i. r is seeded by
+// fixture, so generated values are the same on every run.
+type gen func(i int, r *rand.Rand) any
+
+// omit is returned by a gen to leave the field out of the document.
+type omit struct{}
+
+// seq generates start, start+1, start+2, ... as int32 values.
+func seq(start int) gen {
+	return func(i int, _ *rand.Rand) any { return int32(start + i) }
+}
+
+// cycle generates the values in order, repeating them.
+func cycle(values ...any) gen {
+	return func(i int, _ *rand.Rand) any { return values[i%len(values)] }
+}
+
+// dates generates start, start+step, start+2*step, ...
+func dates(start time.Time, step time.Duration) gen {
+	return func(i int, _ *rand.Rand) any { return start.Add(time.Duration(i) * step) }
+}
+
+// randInt generates random int32 values in [min, max).
+func randInt(min, max int) gen {
+	return func(_ int, r *rand.Rand) any { return int32(min + r.Intn(max-min)) }
+}
+
+// sparse omits the field from a random fraction p of documents, and generates
+// it with g otherwise.
+func sparse(p float64, g gen) gen {
+	return func(i int, r *rand.Rand) any {
+		if r.Float64() < p {
+			return omit{}
+		}
+		return g(i, r)
+	}
+}
+
+// fixture generates n documents from template, in the form newCollection
+// accepts. Template values that are gens are called for each document, and
+// other values are copied as-is. The same seed always generates the same
+// documents.
+//
+// For example, 100 orders spread over 3 regions, with a missing amount in
+// about 10% of them:
+//
+//	docs := fixture(100, 1, bson.D{
+//		{Key: "_id", Value: seq(0)},
+//		{Key: "region", Value: cycle("east", "west", "north")},
+//		{Key: "amount", Value: sparse(0.1, randInt(1, 500))},
+//	})
+func fixture(n int, seed int64, template bson.D) []any {
+	r := rand.New(rand.NewSource(seed))
+	docs := make([]any, n)
+	for i := range docs {
+		doc := make(bson.D, 0, len(template))
+		for _, e := range template {
+			v := e.Value
+			if g, ok := v.(gen); ok {
+				v = g(i, r)
+			}
+			if _, ok := v.(omit); ok {
+				continue
+			}
+			doc = append(doc, bson.E{Key: e.Key, Value: v})
+		}
+		docs[i] = doc
+	}
+	return docs
+}
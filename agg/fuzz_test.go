@@ -0,0 +1,215 @@
+package agg
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fuzzConstructors builds an operator, stage, or pipeline from arbitrary
+// inputs. Constructors that can fail return the error as the second result.
+var fuzzConstructors = []struct {
+	name string
+	fn   func(v any, s string, n int64) (any, error)
+}{
+	{"Abs", func(v any, _ string, _ int64) (any, error) { return Abs(v), nil }},
+	{"Add", func(v any, _ string, _ int64) (any, error) { return Add(v, v), nil }},
+	{"Add/none", func(any, string, int64) (any, error) { return Add(), nil }},
+	{"And", func(v any, _ string, _ int64) (any, error) { return And(v), nil }},
+	{"Avg", func(v any, _ string, _ int64) (any, error) { return Avg(v), nil }},
+	{"Concat", func(v any, s string, _ int64) (any, error) { return Concat(s, v), nil }},
+	{"ConcatSep", func(v any, s string, _ int64) (any, error) { return ConcatSep(s, v, v), nil }},
+	{"Cond", func(v any, _ string, _ int64) (any, error) { return Cond(v, v, v), nil }},
+	{"Divide", func(v any, _ string, _ int64) (any, error) { return Divide(v, v), nil }},
+	{"Eq", func(v any, _ string, _ int64) (any, error) { return Eq(v, v), nil }},
+	{"Filter", func(v any, s string, _ int64) (any, error) { return Filter(v, s, v, v), nil }},
+	{"IfNull", func(v any, _ string, _ int64) (any, error) { return IfNull(v, v), nil }},
+	{"In", func(v any, _ string, _ int64) (any, error) { return In(v, v), nil }},
+	{"Literal", func(v any, _ string, _ int64) (any, error) { return Literal(v), nil }},
+	{"Map", func(v any, s string, _ int64) (any, error) { return Map(v, s, v), nil }},
+	{"Max", func(v any, _ string, _ int64) (any, error) { return Max(v, v), nil }},
+	{"Meta", func(_ any, s string, _ int64) (any, error) { return Meta(s), nil }},
+	{"MergeObjects", func(v any, _ string, _ int64) (any, error) { return MergeObjects(v), nil }},
+	{"Not", func(v any, _ string, _ int64) (any, error) { return Not(v), nil }},
+	{"Reduce", func(v any, _ string, _ int64) (any, error) { return Reduce(v, v, v), nil }},
+	{"Size", func(v any, _ string, _ int64) (any, error) { return Size(v), nil }},
+	{"ToString", func(v any, _ string, _ int64) (any, error) { return ToString(v), nil }},
+	{"Type", func(v any, _ string, _ int64) (any, error) { return Type(v), nil }},
+	{"Coalesce", func(v any, _ string, _ int64) (any, error) { return Coalesce(v, v, v), nil }},
+	{"Coalesce/none", func(any, string, int64) (any, error) { return Coalesce(), nil }},
+	{"DefaultTo", func(v any, s string, _ int64) (any, error) { return DefaultTo(s, v), nil }},
+	{"IsNullOrMissing", func(_ any, s string, _ int64) (any, error) { return IsNullOrMissing(s), nil }},
+	{"CountWhere", func(v any, _ string, _ int64) (any, error) { return CountWhere(v, v), nil }},
+	{"PluckField", func(v any, s string, _ int64) (any, error) { return PluckField(v, s), nil }},
+	{"DateAdd", func(v any, s string, n int64) (any, error) { return DateAdd(v, TimeUnit(s), n, s), nil }},
+	{"DateAddDuration", func(v any, _ string, n int64) (any, error) { return DateAddDuration(v, time.Duration(n)) }},
+	{"DateTrunc", func(v any, s string, n int64) (any, error) { return DateTrunc(v, TimeUnit(s), n, s), nil }},
+	{"Template", func(_ any, s string, _ int64) (any, error) { return Template(s) }},
+	{"Expr", func(v any, _ string, _ int64) (any, error) { return Expr(v).And(v).Or(v).Not(), nil }},
+	{"Window", func(v any, _ string, n int64) (any, error) {
+		bounds := Documents(Offset(-n), Offset(n))
+		if err := bounds.Validate(); err != nil {
+			return nil, err
+		}
+		return Window(Sum(v), bounds), nil
+	}},
+	{"MovingAvg", func(v any, _ string, n int64) (any, error) {
+		if n < 1 {
+			return nil, nil
+		}
+		return MovingAvg(v, n), nil
+	}},
+	{"CumulativeSum", func(v any, _ string, _ int64) (any, error) { return CumulativeSum(v), nil }},
+
+	{"AddFields", func(v any, s string, _ int64) (any, error) { return AddFields(Field(s, v)), nil }},
+	{"AddFields/none", func(any, string, int64) (any, error) { return AddFields(), nil }},
+	{"Count", func(_ any, s string, _ int64) (any, error) { return Count(s), nil }},
+	{"Densify", func(v any, s string, _ int64) (any, error) {
+		return Densify(s, nil, DensifyRange{Step: v, Unit: TimeUnit(s), Bounds: v}), nil
+	}},
+	{"Facet", func(v any, s string, _ int64) (any, error) { return Facet(Field(s, v)), nil }},
+	{"Fill", func(v any, s string, _ int64) (any, error) {
+		return Fill([]string{s}, []SortBy{SortAscending(s)}, Field(s, FillValue(v))), nil
+	}},
+	{"GeoNear", func(v any, s string, _ int64) (any, error) { return GeoNear(v, s), nil }},
+	{"Group", func(v any, s string, _ int64) (any, error) { return Group(v, Field(s, Sum(v))), nil }},
+	{"Limit", func(_ any, _ string, n int64) (any, error) { return Limit(n), nil }},
+	{"Lookup", func(_ any, s string, _ int64) (any, error) { return Lookup(s, s, s, s), nil }},
+	{"LookupPipeline", func(v any, s string, _ int64) (any, error) {
+		return LookupPipeline(s, []FieldExpr{Field(s, v)}, v, s), nil
+	}},
+	{"Match", func(v any, _ string, _ int64) (any, error) { return Match(v), nil }},
+	{"Merge", func(v any, s string, _ int64) (any, error) {
+		return Merge(v, WithMergeOn(s), WithWhenMatched(v), WithWhenNotMatched(s)), nil
+	}},
+	{"Project", func(v any, s string, _ int64) (any, error) { return Project(Field(s, v)), nil }},
+	{"Skip", func(_ any, _ string, n int64) (any, error) { return Skip(n), nil }},
+	{"Sort", func(v any, s string, _ int64) (any, error) { return Sort(SortExpr(s, v)), nil }},
+	{"Sort/none", func(any, string, int64) (any, error) { return Sort(), nil }},
+	{"Unset", func(_ any, s string, _ int64) (any, error) { return Unset(s), nil }},
+	{"Unwind", func(_ any, s string, _ int64) (any, error) { return Unwind(s), nil }},
+	{"SetWindowFields", func(v any, s string, _ int64) (any, error) {
+		return SetWindowFields(v, []SortBy{SortDescending(s)}, Field(s, CumulativeSum(v))), nil
+	}},
+	{"CommentStage", func(_ any, s string, _ int64) (any, error) { return CommentStage(s), nil }},
+	{"GroupBuilder", func(v any, s string, _ int64) (any, error) {
+		return NewGroupBuilder().ID(v).Field(s, Sum(v)).Build()
+	}},
+	{"Join", func(v any, s string, _ int64) (any, error) {
+		return Join(s).LocalField(s).ForeignField(s).Let(s, v).As(s).UnwindOne().Build()
+	}},
+	{"UntrustedField", func(v any, s string, _ int64) (any, error) {
+		f, err := UntrustedField(s, v)
+		if err != nil {
+			return nil, err
+		}
+		return AddFields(f), nil
+	}},
+	{"DateHistogram", func(_ any, s string, n int64) (any, error) {
+		return DateHistogram(s, TimeUnit(s), n, &DateHistogramOptions{FillEmpty: true}), nil
+	}},
+	{"GapFill", func(_ any, s string, n int64) (any, error) {
+		return GapFill(GapFillOptions{TimeField: s, Step: n, Unit: TimeUnit(s), Fields: []string{s}, Method: LOCF}), nil
+	}},
+	{"Pipeline", func(v any, s string, _ int64) (any, error) {
+		return Pipeline{Match(v)}.Append(Project(Field(s, v))).WithComment(s).Clone().Normalize(), nil
+	}},
+}
+
+// fuzzValues returns a set of values derived from the fuzz inputs, including
+// odd values like nil slices and NaN.
+func fuzzValues(s string, n int64, f float64, b bool) []any {
+	return []any{
+		nil,
+		s,
+		"$" + s,
+		n,
+		int32(n),
+		f,
+		math.NaN(),
+		math.Inf(-1),
+		b,
+		bson.A(nil),
+		bson.A{s, n, nil},
+		bson.D(nil),
+		bson.D{{Key: s, Value: f}},
+		bson.M{s: n},
+		[]string(nil),
+		[]any{nil},
+		Operator(nil),
+		Stage(nil),
+		Pipeline(nil),
+		Expr(nil),
+	}
+}
+
+func FuzzConstructorsMarshal(f *testing.F) {
+	f.Add("field", int64(1), 1.5, true)
+	f.Add("", int64(0), 0.0, false)
+	f.Add("$a.b", int64(-1), math.Inf(1), false)
+	f.Add("{a}}{{", int64(math.MinInt64), math.MaxFloat64, true)
+	f.Add("a\x00b", int64(math.MaxInt64), -0.0, false)
+
+	f.Fuzz(func(t *testing.T, s string, n int64, fl float64, b bool) {
+		for _, c := range fuzzConstructors {
+			for _, v := range fuzzValues(s, n, fl, b) {
+				res, err := c.fn(v, s, n)
+				if err != nil {
+					continue
+				}
+
+				doc, err := bson.Marshal(bson.D{{Key: "v", Value: res}})
+				if err != nil {
+					// Null bytes are not allowed in BSON field names, so
+					// constructors that use s as a field name can't marshal.
+					if strings.ContainsRune(s, 0) {
+						continue
+					}
+					t.Fatalf("%s(%#v): error marshaling result %#v: %v", c.name, v, res, err)
+				}
+				if err := bson.Raw(doc).Validate(); err != nil {
+					t.Fatalf("%s(%#v): marshaled invalid BSON: %v", c.name, v, err)
+				}
+			}
+		}
+	})
+}
+
+func FuzzRawPipeline(f *testing.F) {
+	valid, err := bson.Marshal(bson.D{{Key: "0", Value: bson.D{{Key: "$match", Value: bson.D{}}}}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+	f.Add([]byte{5, 0, 0, 0, 0})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, arr []byte) {
+		p, err := RawPipeline(arr)
+		if err != nil {
+			return
+		}
+		if _, err := bson.Marshal(bson.D{{Key: "pipeline", Value: p}}); err != nil {
+			t.Fatalf("error marshaling pipeline decoded from %x: %v", arr, err)
+		}
+	})
+}
+
+func FuzzEscapeFieldName(f *testing.F) {
+	f.Add("a.b")
+	f.Add("$%24%")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, name string) {
+		escaped := EscapeFieldName(name)
+		if got := UnescapeFieldName(escaped); got != name {
+			t.Fatalf("UnescapeFieldName(EscapeFieldName(%q)) = %q", name, got)
+		}
+		if strings.HasPrefix(escaped, "$") || strings.Contains(escaped, ".") {
+			t.Fatalf("EscapeFieldName(%q) = %q, which is not a safe field name", name, escaped)
+		}
+	})
+}
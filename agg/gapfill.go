@@ -0,0 +1,60 @@
+package agg
+
+// GapFillOptions configure the GapFill preset.
+type GapFillOptions struct {
+	// TimeField is the date field to generate missing documents for.
+	TimeField string
+
+	// PartitionByFields are the fields that identify each time series, like a
+	// sensor ID. Documents are generated and filled within each partition.
+	PartitionByFields []string
+
+	// Step and Unit are the expected interval between documents, e.g. 5
+	// Minute.
+	Step int64
+	Unit TimeUnit
+
+	// Bounds is the range to generate documents in: "full", "partition", or
+	// a two-element array with the lower (inclusive) and upper (exclusive)
+	// dates. Defaults to "partition" if PartitionByFields is set, otherwise
+	// "full".
+	Bounds any
+
+	// Fields are the fields to fill in generated documents and any existing
+	// documents with null or missing values.
+	Fields []string
+
+	// Method is the fill method. Defaults to LOCF.
+	Method FillMethod
+}
+
+// GapFill returns the $densify and $fill stages that generate documents for
+// missing intervals of a time series and fill in their field values, e.g. to
+// fill missing sensor readings with the last reading.
+func GapFill(opts GapFillOptions) Pipeline {
+	bounds := opts.Bounds
+	if bounds == nil {
+		bounds = "full"
+		if len(opts.PartitionByFields) > 0 {
+			bounds = "partition"
+		}
+	}
+	method := opts.Method
+	if method == "" {
+		method = LOCF
+	}
+
+	output := make([]FieldExpr, len(opts.Fields))
+	for i, field := range opts.Fields {
+		output[i] = Field(field, FillBy(method))
+	}
+
+	return Pipeline{
+		Densify(opts.TimeField, opts.PartitionByFields, DensifyRange{
+			Step:   opts.Step,
+			Unit:   opts.Unit,
+			Bounds: bounds,
+		}),
+		Fill(opts.PartitionByFields, []SortBy{SortAscending(opts.TimeField)}, output...),
+	}
+}
@@ -0,0 +1,160 @@
+// Package geo provides GeoJSON geometry types for use with the $geoNear
+// aggregation stage and the $geoWithin and $geoIntersects query operators.
+//
+// GeoJSON positions are [longitude, latitude], which is the reverse of the
+// order most people say coordinates in. To prevent accidentally swapping them,
+// positions are created with LngLat or LatLng and are validated when
+// marshaled.
+//
+// For example:
+//
+//	agg.GeoNear(geo.Point(geo.LatLng(40.76, -73.98)), "distance")
+package geo
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Position is a GeoJSON position.
+type Position struct {
+	Longitude float64
+	Latitude  float64
+}
+
+func LngLat(lng, lat float64) Position {
+	return Position{Longitude: lng, Latitude: lat}
+}
+
+func LatLng(lat, lng float64) Position {
+	return Position{Longitude: lng, Latitude: lat}
+}
+
+// Validate returns an error if the longitude is not in [-180, 180] or the
+// latitude is not in [-90, 90].
+func (p Position) Validate() error {
+	lngOK := p.Longitude >= -180 && p.Longitude <= 180
+	latOK := p.Latitude >= -90 && p.Latitude <= 90
+	switch {
+	case lngOK && latOK:
+		return nil
+	case !latOK && p.Latitude >= -180 && p.Latitude <= 180 && p.Longitude >= -90 && p.Longitude <= 90:
+		return fmt.Errorf("invalid position %v: latitude %v is out of range [-90, 90], longitude and latitude may be swapped",
+			p,
+			p.Latitude)
+	case !lngOK:
+		return fmt.Errorf("invalid position %v: longitude %v is out of range [-180, 180]", p, p.Longitude)
+	default:
+		return fmt.Errorf("invalid position %v: latitude %v is out of range [-90, 90]", p, p.Latitude)
+	}
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("[%v, %v]", p.Longitude, p.Latitude)
+}
+
+func (p Position) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if err := p.Validate(); err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(bson.A{p.Longitude, p.Latitude})
+}
+
+type Point Position
+
+func (p Point) Validate() error {
+	return Position(p).Validate()
+}
+
+func (p Point) MarshalBSON() ([]byte, error) {
+	return marshalGeometry("Point", Position(p))
+}
+
+// LineString is a line through two or more positions.
+type LineString []Position
+
+func (ls LineString) Validate() error {
+	if len(ls) < 2 {
+		return fmt.Errorf("line string must have at least 2 positions, got %d", len(ls))
+	}
+	for _, p := range ls {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ls LineString) MarshalBSON() ([]byte, error) {
+	if err := ls.Validate(); err != nil {
+		return nil, err
+	}
+	return marshalGeometry("LineString", []Position(ls))
+}
+
+// Polygon is a list of linear rings. The first ring is the exterior ring and
+// any others are holes within it. Each ring must be closed, i.e. start and end
+// with the same position, and have at least 4 positions.
+type Polygon [][]Position
+
+func (pg Polygon) Validate() error {
+	if len(pg) == 0 {
+		return errors.New("polygon must have at least 1 ring")
+	}
+	for i, ring := range pg {
+		if len(ring) < 4 {
+			return fmt.Errorf("polygon ring %d must have at least 4 positions, got %d", i, len(ring))
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("polygon ring %d must be closed: first position %v and last position %v are different",
+				i,
+				ring[0],
+				ring[len(ring)-1])
+		}
+		for _, p := range ring {
+			if err := p.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pg Polygon) MarshalBSON() ([]byte, error) {
+	if err := pg.Validate(); err != nil {
+		return nil, err
+	}
+	return marshalGeometry("Polygon", [][]Position(pg))
+}
+
+type MultiPolygon []Polygon
+
+func (mp MultiPolygon) Validate() error {
+	for i, pg := range mp {
+		if err := pg.Validate(); err != nil {
+			return fmt.Errorf("invalid polygon %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (mp MultiPolygon) MarshalBSON() ([]byte, error) {
+	if err := mp.Validate(); err != nil {
+		return nil, err
+	}
+	coords := make([][][]Position, len(mp))
+	for i := range mp {
+		coords[i] = mp[i]
+	}
+	return marshalGeometry("MultiPolygon", coords)
+}
+
+func marshalGeometry(typ string, coordinates any) ([]byte, error) {
+	return bson.Marshal(bson.D{
+		{Key: "type", Value: typ},
+		{Key: "coordinates", Value: coordinates},
+	})
+}
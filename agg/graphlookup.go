@@ -0,0 +1,164 @@
+package agg
+
+import (
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GraphLookupBuilder builds a $graphLookup stage, which recursively searches
+// the "from" collection, and the stages that commonly follow it. Use
+// Ancestors and Descendants for the common tree traversal patterns.
+type GraphLookupBuilder struct {
+	from             string
+	startWith        any
+	connectFromField string
+	connectToField   string
+	as               string
+	maxDepth         *int64
+	depthField       string
+	restrict         any
+	sortByDepth      bool
+}
+
+// GraphLookup starts building a recursive search of the "from" collection.
+func GraphLookup(from string) *GraphLookupBuilder {
+	return &GraphLookupBuilder{from: from}
+}
+
+// StartWith sets the expression for the value of ConnectFromField to start
+// the search with, like "$parentId".
+func (gb *GraphLookupBuilder) StartWith(expr any) *GraphLookupBuilder {
+	gb.startWith = expr
+	return gb
+}
+
+// ConnectFromField sets the field whose value is matched against
+// ConnectToField to find the next documents in the search.
+func (gb *GraphLookupBuilder) ConnectFromField(field string) *GraphLookupBuilder {
+	gb.connectFromField = field
+	return gb
+}
+
+func (gb *GraphLookupBuilder) ConnectToField(field string) *GraphLookupBuilder {
+	gb.connectToField = field
+	return gb
+}
+
+// As sets the output array field. It defaults to the "from" collection name.
+func (gb *GraphLookupBuilder) As(field string) *GraphLookupBuilder {
+	gb.as = field
+	return gb
+}
+
+// MaxDepth limits the number of recursions. A MaxDepth of 0 only finds the
+// documents that match StartWith.
+func (gb *GraphLookupBuilder) MaxDepth(n int64) *GraphLookupBuilder {
+	gb.maxDepth = &n
+	return gb
+}
+
+// DepthField records the number of recursions needed to reach each found
+// document in the field. Documents reachable by multiple paths get the depth
+// of the shortest one.
+func (gb *GraphLookupBuilder) DepthField(field string) *GraphLookupBuilder {
+	gb.depthField = field
+	return gb
+}
+
+// RestrictSearchWithMatch only searches documents in the "from" collection
+// that match the query filter.
+func (gb *GraphLookupBuilder) RestrictSearchWithMatch(filter any) *GraphLookupBuilder {
+	gb.restrict = filter
+	return gb
+}
+
+// SortByDepth sorts the output array from the nearest to the farthest
+// document, since $graphLookup doesn't guarantee any order. It sets
+// DepthField to "depth" if it isn't set. Requires MongoDB 5.2.
+func (gb *GraphLookupBuilder) SortByDepth() *GraphLookupBuilder {
+	gb.sortByDepth = true
+	return gb
+}
+
+func (gb *GraphLookupBuilder) Build() (Pipeline, error) {
+	if gb.from == "" {
+		return nil, errors.New("graph lookup collection must not be empty")
+	}
+	if gb.startWith == nil {
+		return nil, errors.New("graph lookup must set StartWith")
+	}
+	if gb.connectFromField == "" || gb.connectToField == "" {
+		return nil, errors.New("graph lookup must set ConnectFromField and ConnectToField")
+	}
+	if gb.maxDepth != nil && *gb.maxDepth < 0 {
+		return nil, errors.New("graph lookup MaxDepth must not be negative")
+	}
+
+	as := gb.as
+	if as == "" {
+		as = gb.from
+	}
+	depthField := gb.depthField
+	if depthField == "" && gb.sortByDepth {
+		depthField = "depth"
+	}
+
+	body := make(bson.D, 0, 8)
+	body = append(body,
+		bson.E{Key: "from", Value: gb.from},
+		bson.E{Key: "startWith", Value: gb.startWith},
+		bson.E{Key: "connectFromField", Value: gb.connectFromField},
+		bson.E{Key: "connectToField", Value: gb.connectToField},
+		bson.E{Key: "as", Value: as})
+	if gb.maxDepth != nil {
+		body = append(body, bson.E{Key: "maxDepth", Value: *gb.maxDepth})
+	}
+	if depthField != "" {
+		body = append(body, bson.E{Key: "depthField", Value: depthField})
+	}
+	if gb.restrict != nil {
+		body = append(body, bson.E{Key: "restrictSearchWithMatch", Value: gb.restrict})
+	}
+
+	stages := Pipeline{{{Key: "$graphLookup", Value: body}}}
+	if gb.sortByDepth {
+		stages = append(stages, AddFields(Field(as,
+			SortArray("$"+as, bson.D{{Key: depthField, Value: 1}}))))
+	}
+	return stages, nil
+}
+
+// Ancestors starts building a search for the ancestors of each input document
+// in a tree where each document in the "from" collection stores its parent's
+// _id in parentField. The ancestors are output in the "as" field, sorted from
+// the parent up to the root, with their distance from the input document
+// (starting at 0 for the parent) in the "depth" field.
+//
+// For example, to find the breadcrumbs of each category:
+//
+//	stages, err := agg.Ancestors("categories", "parentId", "breadcrumbs").Build()
+func Ancestors(from, parentField, as string) *GraphLookupBuilder {
+	return GraphLookup(from).
+		StartWith(fieldPathValue(parentField)).
+		ConnectFromField(strings.TrimPrefix(parentField, "$")).
+		ConnectToField("_id").
+		As(as).
+		SortByDepth()
+}
+
+// Descendants starts building a search for the descendants of each input
+// document in a tree where each document in the "from" collection stores its
+// parent's _id in parentField. The descendants are output in the "as" field,
+// sorted by their distance from the input document (starting at 0 for its
+// children) in the "depth" field. Use MaxDepth to limit the search, e.g. to
+// only find children and grandchildren.
+func Descendants(from, parentField, as string) *GraphLookupBuilder {
+	return GraphLookup(from).
+		StartWith("$_id").
+		ConnectFromField("_id").
+		ConnectToField(strings.TrimPrefix(parentField, "$")).
+		As(as).
+		SortByDepth()
+}
@@ -0,0 +1,153 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GroupBuilder builds a $group stage, validating that every output field is
+// computed with an accumulator and that output field names are unique.
+type GroupBuilder struct {
+	id     any
+	fields []FieldExpr
+}
+
+func NewGroupBuilder() *GroupBuilder {
+	return &GroupBuilder{}
+}
+
+// ID sets the group key expression. If ID is never called, all input
+// documents are grouped together (i.e. "_id: null").
+func (gb *GroupBuilder) ID(expr any) *GroupBuilder {
+	gb.id = expr
+	return gb
+}
+
+func (gb *GroupBuilder) Field(name string, accumulator any) *GroupBuilder {
+	gb.fields = append(gb.fields, Field(name, accumulator))
+	return gb
+}
+
+func (gb *GroupBuilder) Build() (Stage, error) {
+	var errs []error
+	seen := make(map[string]bool, len(gb.fields))
+	for _, field := range gb.fields {
+		switch {
+		case field.Key == "":
+			errs = append(errs, errors.New("group output field name must not be empty"))
+			continue
+		case field.Key == "_id":
+			errs = append(errs, errors.New(`group output field name "_id" is reserved for the group key, use ID instead`))
+			continue
+		case strings.HasPrefix(field.Key, "$"), strings.Contains(field.Key, "."):
+			errs = append(errs, fmt.Errorf("group output field name %q must not start with '$' or contain '.'", field.Key))
+			continue
+		case seen[field.Key]:
+			errs = append(errs, fmt.Errorf("duplicate group output field name %q", field.Key))
+			continue
+		}
+		seen[field.Key] = true
+
+		if name, ok := accumulatorName(field.Value); !ok {
+			errs = append(errs, fmt.Errorf("group output field %q must be an accumulator expression, got %s", field.Key, name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return Group(gb.id, gb.fields...), nil
+}
+
+// accumulatorName returns the top-level operator name of v and whether it's a
+// valid $group accumulator.
+func accumulatorName(v any) (string, bool) {
+	var keys []string
+	switch v := v.(type) {
+	case Operator:
+		for _, e := range v {
+			keys = append(keys, e.Key)
+		}
+	case bson.D:
+		for _, e := range v {
+			keys = append(keys, e.Key)
+		}
+	case bson.M:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]any:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	default:
+		return fmt.Sprintf("%T", v), false
+	}
+	if len(keys) != 1 {
+		return fmt.Sprintf("document with %d fields", len(keys)), false
+	}
+	if info, ok := LookupOperator(keys[0]); !ok || !info.Accumulator {
+		return fmt.Sprintf("%q", keys[0]), false
+	}
+	return keys[0], true
+}
+
+// GroupKey builds a composite $group key document. String values are treated
+// as field paths and are prefixed with '$' if they aren't already. All other
+// values are used as-is.
+//
+// For example:
+//
+//	agg.Group(agg.GroupKey(
+//		agg.Field("year", bson.D{{Key: "$year", Value: "$date"}}),
+//		agg.Field("color", "color"),
+//	))
+func GroupKey(fields ...FieldExpr) bson.D {
+	d := make(bson.D, len(fields))
+	for i := range fields {
+		d[i] = bson.E{Key: fields[i].Key, Value: fieldPathValue(fields[i].Value)}
+	}
+	return d
+}
+
+// GroupKeyStruct builds a composite $group key document from the fields of
+// the struct v, named by their "bson" struct tags. Non-empty string values are
+// treated as field paths and zero values reference the input field with the
+// same name, so the same struct type can be used to decode the resulting
+// "_id" document.
+//
+// For example:
+//
+//	type key struct {
+//		Color string `bson:"color"`
+//		Size  string `bson:"size"`
+//	}
+//	agg.GroupKeyStruct(key{Size: "dimensions.size"})
+//
+// builds the key {color: "$color", size: "$dimensions.size"}.
+func GroupKeyStruct(v any) (bson.D, error) {
+	fields, err := structFields(v)
+	if err != nil {
+		return nil, err
+	}
+
+	d := make(bson.D, len(fields))
+	for i, f := range fields {
+		var value any = "$" + f.name
+		if !f.value.IsZero() {
+			value = fieldPathValue(f.value.Interface())
+		}
+		d[i] = bson.E{Key: f.name, Value: value}
+	}
+	return d, nil
+}
+
+func fieldPathValue(v any) any {
+	if s, ok := v.(string); ok && !strings.HasPrefix(s, "$") {
+		return "$" + s
+	}
+	return v
+}
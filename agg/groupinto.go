@@ -0,0 +1,125 @@
+package agg
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GroupResult is a group result with the group key decoded as K and the
+// accumulated fields decoded as V.
+type GroupResult[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// GroupInto runs pipeline followed by a $group stage on key with the given
+// accumulators, and returns the results as a map from each decoded group key
+// to the decoded accumulator fields.
+//
+// If V is a struct, GroupInto checks that its fields match the accumulator
+// names before running the pipeline, so the result struct can't silently
+// drift from the pipeline definition. V may also have an "_id" field, which
+// gets the group key.
+//
+// For example:
+//
+//	type stats struct {
+//		Total int64   `bson:"total"`
+//		Avg   float64 `bson:"avg"`
+//	}
+//	byColor, err := agg.GroupInto[string, stats](ctx, coll, nil, "$color",
+//		agg.Field("total", acc.Sum("$qty")),
+//		agg.Field("avg", acc.Avg("$qty")))
+func GroupInto[K comparable, V any](
+	ctx context.Context,
+	coll *mongo.Collection,
+	pipeline Pipeline,
+	key any,
+	accumulators ...FieldExpr,
+) (map[K]V, error) {
+	groups, err := GroupIntoSlice[K, V](ctx, coll, pipeline, key, accumulators...)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[K]V, len(groups))
+	for _, g := range groups {
+		if _, ok := m[g.Key]; ok {
+			return nil, fmt.Errorf("multiple groups decoded to the same key %v", g.Key)
+		}
+		m[g.Key] = g.Value
+	}
+	return m, nil
+}
+
+// GroupIntoSlice is like GroupInto, but returns the groups as a slice in the
+// order the server returned them. It supports group keys that can't be map
+// keys, like documents decoded into a struct containing a slice.
+func GroupIntoSlice[K, V any](
+	ctx context.Context,
+	coll *mongo.Collection,
+	pipeline Pipeline,
+	key any,
+	accumulators ...FieldExpr,
+) ([]GroupResult[K, V], error) {
+	if err := checkGroupFields[V](accumulators); err != nil {
+		return nil, err
+	}
+
+	cursor, err := Aggregate(ctx, coll, pipeline.Append(Group(key, accumulators...)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []GroupResult[K, V]
+	for cursor.Next(ctx) {
+		var g GroupResult[K, V]
+		if err := cursor.Current.Lookup("_id").Unmarshal(&g.Key); err != nil {
+			return nil, fmt.Errorf("error decoding group key: %w", err)
+		}
+		if err := cursor.Decode(&g.Value); err != nil {
+			return nil, fmt.Errorf("error decoding group %v: %w", g.Key, err)
+		}
+		groups = append(groups, g)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// checkGroupFields returns an error if V is a struct and its fields don't
+// match the accumulator names.
+func checkGroupFields[V any](accumulators []FieldExpr) error {
+	var v V
+	if reflect.TypeOf(&v).Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	fields, err := structFields(&v)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(accumulators))
+	for _, a := range accumulators {
+		names[a.Key] = true
+	}
+	for _, f := range fields {
+		if f.name == "_id" {
+			continue
+		}
+		if !names[f.name] {
+			return fmt.Errorf("field %q of %T has no matching accumulator", f.name, v)
+		}
+		delete(names, f.name)
+	}
+	for _, a := range accumulators {
+		if names[a.Key] {
+			return fmt.Errorf("accumulator %q has no matching field in %T", a.Key, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+//go:build integration
+
+package agg_test
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/matthewdale/mongo-go-exp/agg"
+	"github.com/matthewdale/mongo-go-exp/agg/acc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Run the integration tests against a live mongod with:
+//
+//	MONGODB_URI=mongodb://localhost:27017 go test -tags integration ./agg/...
+
+func newCollection(t *testing.T, docs ...any) *mongo.Collection {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("error connecting to %q: %v", uri, err)
+	}
+	t.Cleanup(func() {
+		_ = client.Disconnect(context.Background())
+	})
+
+	coll := client.Database("mongo_go_exp_agg_test").Collection(t.Name())
+	if err := coll.Drop(ctx); err != nil {
+		t.Fatalf("error dropping collection: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = coll.Drop(context.Background())
+	})
+
+	if len(docs) > 0 {
+		if _, err := coll.InsertMany(ctx, docs); err != nil {
+			t.Fatalf("error seeding fixture documents: %v", err)
+		}
+	}
+
+	return coll
+}
+
+func aggregate(t *testing.T, coll *mongo.Collection, pipeline any) []bson.M {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cur, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		t.Fatalf("error running aggregate: %v", err)
+	}
+	var res []bson.M
+	if err := cur.All(ctx, &res); err != nil {
+		t.Fatalf("error reading aggregate results: %v", err)
+	}
+	return res
+}
+
+func assertResults(t *testing.T, want, got []bson.M) {
+	t.Helper()
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("results are different\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+var fruit = []any{
+	bson.M{"_id": 1, "name": "apple", "color": "red", "qty": int32(5), "tags": bson.A{"sweet", "crisp"}},
+	bson.M{"_id": 2, "name": "banana", "color": "yellow", "qty": int32(12), "tags": bson.A{"sweet"}},
+	bson.M{"_id": 3, "name": "cherry", "color": "red", "qty": int32(40), "tags": bson.A{"sour", "small"}},
+	bson.M{"_id": 4, "name": "lemon", "color": "yellow", "qty": int32(0), "tags": bson.A{"sour"}},
+}
+
+func TestIntegrationMatchSortProject(t *testing.T) {
+	coll := newCollection(t, fruit...)
+
+	got := aggregate(t, coll, []bson.D{
+		agg.Match(bson.M{"color": "red"}),
+		agg.Sort(agg.SortDescending("qty")),
+		agg.Project(agg.Field("_id", 0), agg.Field("name", 1)),
+	})
+
+	assertResults(t, []bson.M{
+		{"name": "cherry"},
+		{"name": "apple"},
+	}, got)
+}
+
+func TestIntegrationAddFieldsUnset(t *testing.T) {
+	coll := newCollection(t, fruit...)
+
+	got := aggregate(t, coll, []bson.D{
+		agg.AddFields(
+			agg.Field("inStock", agg.Ne("$qty", 0)),
+			agg.Field("half", agg.Divide("$qty", 2)),
+		),
+		agg.Unset("tags", "color", "qty", "name"),
+		agg.Sort(agg.SortAscending("_id")),
+	})
+
+	assertResults(t, []bson.M{
+		{"_id": int32(1), "inStock": true, "half": 2.5},
+		{"_id": int32(2), "inStock": true, "half": 6.0},
+		{"_id": int32(3), "inStock": true, "half": 20.0},
+		{"_id": int32(4), "inStock": false, "half": 0.0},
+	}, got)
+}
+
+func TestIntegrationGroupCount(t *testing.T) {
+	coll := newCollection(t, fruit...)
+
+	got := aggregate(t, coll, []bson.D{
+		agg.Group("$color",
+			agg.Field("total", acc.Sum("$qty")),
+			agg.Field("n", acc.Count()),
+			agg.Field("most", acc.Max("$qty")),
+			agg.Field("least", acc.Min("$qty")),
+		),
+		agg.Sort(agg.SortAscending("_id")),
+	})
+
+	assertResults(t, []bson.M{
+		{"_id": "red", "total": int32(45), "n": int32(2), "most": int32(40), "least": int32(5)},
+		{"_id": "yellow", "total": int32(12), "n": int32(2), "most": int32(12), "least": int32(0)},
+	}, got)
+
+	got = aggregate(t, coll, []bson.D{
+		agg.Match(bson.M{"qty": bson.M{"$gt": 1}}),
+		agg.Count("n"),
+	})
+
+	assertResults(t, []bson.M{{"n": int32(3)}}, got)
+}
+
+func TestIntegrationUnwind(t *testing.T) {
+	coll := newCollection(t, fruit...)
+
+	got := aggregate(t, coll, []bson.D{
+		agg.Unwind("$tags"),
+		agg.Group("$tags", agg.Field("n", acc.Sum(1))),
+		agg.Sort(agg.SortAscending("_id")),
+	})
+
+	assertResults(t, []bson.M{
+		{"_id": "crisp", "n": int32(1)},
+		{"_id": "small", "n": int32(1)},
+		{"_id": "sour", "n": int32(2)},
+		{"_id": "sweet", "n": int32(2)},
+	}, got)
+}
+
+func TestIntegrationArrayOperators(t *testing.T) {
+	coll := newCollection(t, fruit...)
+
+	got := aggregate(t, coll, []bson.D{
+		agg.Match(bson.M{"_id": 1}),
+		agg.Project(
+			agg.Field("_id", 0),
+			agg.Field("upper", agg.Map("$tags", "tag", bson.M{"$toUpper": "$$tag"})),
+			agg.Field("sweet", agg.Filter("$tags", "tag", agg.Eq("$$tag", "sweet"), nil)),
+			agg.Field("joined", agg.Reduce("$tags", "", bson.M{"$concat": bson.A{"$$value", "$$this"}})),
+			agg.Field("hasCrisp", agg.In("crisp", "$tags")),
+		),
+	})
+
+	assertResults(t, []bson.M{{
+		"upper":    bson.A{"SWEET", "CRISP"},
+		"sweet":    bson.A{"sweet"},
+		"joined":   "sweetcrisp",
+		"hasCrisp": true,
+	}}, got)
+}
+
+func TestIntegrationConditionals(t *testing.T) {
+	coll := newCollection(t, fruit...)
+
+	got := aggregate(t, coll, []bson.D{
+		agg.Project(
+			agg.Field("size", agg.Cond(
+				agg.Or(agg.Eq("$qty", 0), agg.Eq("$color", "red")),
+				"special",
+				"normal",
+			)),
+			agg.Field("abs", agg.Abs(-1)),
+			agg.Field("merged", agg.MergeObjects(bson.M{"a": 1}, bson.M{"b": 2})),
+		),
+		agg.Sort(agg.SortAscending("_id")),
+	})
+
+	assertResults(t, []bson.M{
+		{"_id": int32(1), "size": "special", "abs": int32(1), "merged": bson.M{"a": int32(1), "b": int32(2)}},
+		{"_id": int32(2), "size": "normal", "abs": int32(1), "merged": bson.M{"a": int32(1), "b": int32(2)}},
+		{"_id": int32(3), "size": "special", "abs": int32(1), "merged": bson.M{"a": int32(1), "b": int32(2)}},
+		{"_id": int32(4), "size": "special", "abs": int32(1), "merged": bson.M{"a": int32(1), "b": int32(2)}},
+	}, got)
+}
+
+func TestIntegrationTopBottom(t *testing.T) {
+	coll := newCollection(t, fruit...)
+
+	got := aggregate(t, coll, []bson.D{
+		agg.Group("$color",
+			agg.Field("top", acc.Top("$name", agg.SortDescending("qty"))),
+			agg.Field("bottom", acc.Bottom("$name", agg.SortDescending("qty"))),
+			agg.Field("bottomN", acc.BottomNExpr("$name", 1, agg.SortDescending("qty"))),
+			agg.Field("topN", acc.TopNExpr("$name", 2, agg.SortAscending("qty"))),
+		),
+		agg.Sort(agg.SortAscending("_id")),
+	})
+
+	assertResults(t, []bson.M{
+		{"_id": "red", "top": "cherry", "bottom": "apple", "bottomN": bson.A{"apple"}, "topN": bson.A{"apple", "cherry"}},
+		{"_id": "yellow", "top": "banana", "bottom": "lemon", "bottomN": bson.A{"lemon"}, "topN": bson.A{"lemon", "banana"}},
+	}, got)
+}
+
+func TestIntegrationFixtureGroupCounts(t *testing.T) {
+	docs := fixture(300, 1, bson.D{
+		{Key: "_id", Value: seq(0)},
+		{Key: "region", Value: cycle("east", "west", "north")},
+		{Key: "amount", Value: sparse(0.1, randInt(1, 500))},
+	})
+	coll := newCollection(t, docs...)
+
+	want := map[string]bson.M{}
+	for _, d := range docs {
+		m := d.(bson.D).Map()
+		region := m["region"].(string)
+		if want[region] == nil {
+			want[region] = bson.M{"_id": region, "n": int32(0), "withAmount": int32(0)}
+		}
+		want[region]["n"] = want[region]["n"].(int32) + 1
+		if _, ok := m["amount"]; ok {
+			want[region]["withAmount"] = want[region]["withAmount"].(int32) + 1
+		}
+	}
+
+	got := aggregate(t, coll, []bson.D{
+		agg.Group("$region",
+			agg.Field("n", acc.Count()),
+			agg.Field("withAmount", acc.Sum(agg.Cond(agg.IsMissing("amount"), 0, 1))),
+		),
+		agg.Sort(agg.SortAscending("_id")),
+	})
+
+	assertResults(t, []bson.M{want["east"], want["north"], want["west"]}, got)
+}
+
+func TestIntegrationFixtureCumulativeSum(t *testing.T) {
+	docs := fixture(50, 1, bson.D{
+		{Key: "_id", Value: seq(0)},
+		{Key: "day", Value: dates(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 24*time.Hour)},
+		{Key: "qty", Value: randInt(0, 10)},
+	})
+	coll := newCollection(t, docs...)
+
+	var want []bson.M
+	var total int32
+	for _, d := range docs {
+		m := d.(bson.D).Map()
+		total += m["qty"].(int32)
+		want = append(want, bson.M{"_id": m["_id"], "total": total})
+	}
+
+	got := aggregate(t, coll, []bson.D{
+		agg.CumulativeSumStage("total", "$qty", nil, agg.SortAscending("day")),
+		agg.Project(agg.Field("total", 1)),
+		agg.Sort(agg.SortAscending("_id")),
+	})
+
+	assertResults(t, want, got)
+}
@@ -0,0 +1,143 @@
+package agg
+
+import "sort"
+
+// ReferencedFields returns the sorted, de-duplicated field paths the pipeline
+// reads from its input documents or from fields computed by earlier stages,
+// including fields a $project includes or excludes and those in $facet
+// sub-pipelines. Fields of other collections read
+// by $lookup, $graphLookup, and $unionWith are not included.
+func (p Pipeline) ReferencedFields() []string {
+	seen := make(map[string]bool)
+	p.collectFields(seen)
+
+	fields := make([]string, 0, len(seen))
+	for f := range seen {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (p Pipeline) collectFields(seen map[string]bool) {
+	for _, s := range p {
+		body := stageBody(s)
+		var refs []string
+		switch stageName(s) {
+		case "$facet":
+			for _, sub := range stageSubPipelines(s) {
+				sub.collectFields(seen)
+			}
+		case "$lookup":
+			if lf, ok := lookupKey(body, "localField"); ok {
+				if s, ok := lf.(string); ok {
+					refs = append(refs, s)
+				}
+			}
+			if let, ok := lookupKey(body, "let"); ok {
+				refs = append(refs, fieldPathRefs(let)...)
+			}
+		case "$graphLookup":
+			if sw, ok := lookupKey(body, "startWith"); ok {
+				refs = append(refs, fieldPathRefs(sw)...)
+			}
+		case "$unionWith":
+		case "$project":
+			// Included and excluded fields expose or hide input fields, so
+			// they're references even though they aren't "$path" strings.
+			refs = append(stageFieldRefs(s), projectionPaths(body)...)
+		default:
+			refs = stageFieldRefs(s)
+		}
+		for _, r := range refs {
+			seen[r] = true
+		}
+	}
+}
+
+// CollectionRef is a collection read or written by a pipeline stage.
+type CollectionRef struct {
+	// DB is the database name, or empty for the database the pipeline runs
+	// in.
+	DB         string
+	Collection string
+
+	// Stage is the name of the stage that references the collection, like
+	// "$lookup".
+	Stage string
+	// Write is true if the stage writes to the collection ($merge and $out).
+	Write bool
+}
+
+// ReferencedCollections returns the collections read by $lookup,
+// $graphLookup, and $unionWith stages and written by $merge and $out stages,
+// including those in sub-pipelines, in the order they appear. The collection
+// the pipeline runs against is not included.
+func (p Pipeline) ReferencedCollections() []CollectionRef {
+	var refs []CollectionRef
+	p.collectCollections(&refs)
+	return refs
+}
+
+func (p Pipeline) collectCollections(refs *[]CollectionRef) {
+	add := func(ref CollectionRef) {
+		if ref.Collection == "" {
+			return
+		}
+		for _, r := range *refs {
+			if r == ref {
+				return
+			}
+		}
+		*refs = append(*refs, ref)
+	}
+
+	for _, s := range p {
+		name := stageName(s)
+		body := stageBody(s)
+		switch name {
+		case "$lookup", "$graphLookup":
+			if from, ok := lookupKey(body, "from"); ok {
+				db, coll := namespace(from)
+				add(CollectionRef{DB: db, Collection: coll, Stage: name})
+			}
+		case "$unionWith":
+			coll, ok := lookupKey(body, "coll")
+			if !ok {
+				coll = body
+			}
+			db, c := namespace(coll)
+			add(CollectionRef{DB: db, Collection: c, Stage: name})
+		case "$merge":
+			into, ok := lookupKey(body, "into")
+			if !ok {
+				into = body
+			}
+			db, coll := namespace(into)
+			add(CollectionRef{DB: db, Collection: coll, Stage: name, Write: true})
+		case "$out":
+			db, coll := namespace(body)
+			add(CollectionRef{DB: db, Collection: coll, Stage: name, Write: true})
+		}
+
+		for _, sub := range stageSubPipelines(s) {
+			sub.collectCollections(refs)
+		}
+	}
+}
+
+// namespace returns the database and collection names from v, which is a
+// collection name or a {db, coll} document.
+func namespace(v any) (string, string) {
+	if s, ok := v.(string); ok {
+		return "", s
+	}
+	var db, coll string
+	if d, ok := lookupKey(v, "db"); ok {
+		db, _ = d.(string)
+	}
+	if c, ok := lookupKey(v, "coll"); ok {
+		coll, _ = c.(string)
+	}
+	return db, coll
+}
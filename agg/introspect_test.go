@@ -0,0 +1,52 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestReferencedFields(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Pipeline
+		want []string
+	}{
+		{
+			name: "inclusion projection",
+			p:    Pipeline{Project(Field("ssn", 1))},
+			want: []string{"ssn"},
+		},
+		{
+			name: "exclusion projection",
+			p:    Pipeline{Project(Field("_id", 0), Field("ssn", false))},
+			want: []string{"_id", "ssn"},
+		},
+		{
+			name: "nested projection",
+			p:    Pipeline{Project(Field("a", bson.D{{Key: "b", Value: 1}, {Key: "c", Value: bson.D{{Key: "d", Value: true}}}}))},
+			want: []string{"a.b", "a.c.d"},
+		},
+		{
+			name: "computed projection",
+			p:    Pipeline{Project(Field("full", Concat("$first", "$last")), Field("x", "$y"))},
+			want: []string{"first", "last", "y"},
+		},
+		{
+			name: "match and group",
+			p: Pipeline{
+				Match(bson.D{{Key: "status", Value: "A"}}),
+				Group("$cust", Field("total", Operator{{Key: "$sum", Value: "$amount"}})),
+			},
+			want: []string{"amount", "cust", "status"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.p.ReferencedFields(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
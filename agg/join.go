@@ -0,0 +1,222 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// JoinBuilder builds a $lookup stage and the stages that commonly follow it
+// to get inner-join or one-to-one join semantics.
+//
+// For example, to replace each order's "customerId" with the matching
+// customer document, dropping orders without a customer:
+//
+//	stages, err := agg.Join("customers").
+//		LocalField("customerId").
+//		ForeignField("_id").
+//		As("customer").
+//		UnwindOne().
+//		Inner().
+//		Build()
+type JoinBuilder struct {
+	from         string
+	localField   string
+	foreignField string
+	let          []FieldExpr
+	pipeline     Pipeline
+	as           string
+	unwindOne    bool
+	inner        bool
+}
+
+// Join starts building a join with the documents in the "from" collection.
+func Join(from string) *JoinBuilder {
+	return &JoinBuilder{from: from}
+}
+
+func (jb *JoinBuilder) LocalField(field string) *JoinBuilder {
+	jb.localField = field
+	return jb
+}
+
+func (jb *JoinBuilder) ForeignField(field string) *JoinBuilder {
+	jb.foreignField = field
+	return jb
+}
+
+// Let declares a variable that can be referenced as "$$<name>" in the join
+// pipeline.
+func (jb *JoinBuilder) Let(name string, expr any) *JoinBuilder {
+	jb.let = append(jb.let, Field(name, expr))
+	return jb
+}
+
+// Var declares a variable like Let and returns a reference to it for use in
+// the join pipeline. Build returns an error if the pipeline uses a Var with a
+// name that isn't declared on the JoinBuilder, so correlated subqueries can't
+// reference undeclared variables. For example:
+//
+//	jb := agg.Join("inventory").As("stock")
+//	item := jb.Var("item", "$item")
+//	stages, err := jb.Pipeline(
+//		agg.Match(agg.Expr(agg.Eq("$sku", item))),
+//	).Build()
+func (jb *JoinBuilder) Var(name string, expr any) Var {
+	jb.Let(name, expr)
+	return Var{name: name}
+}
+
+// Pipeline sets the pipeline run on the joined collection.
+func (jb *JoinBuilder) Pipeline(stages ...Stage) *JoinBuilder {
+	jb.pipeline = append(Pipeline(nil), stages...)
+	return jb
+}
+
+// As sets the output array field. It defaults to the "from" collection name.
+func (jb *JoinBuilder) As(field string) *JoinBuilder {
+	jb.as = field
+	return jb
+}
+
+// UnwindOne replaces the output array with the single joined document, for
+// one-to-one joins.
+func (jb *JoinBuilder) UnwindOne() *JoinBuilder {
+	jb.unwindOne = true
+	return jb
+}
+
+// Inner drops input documents that have no joined documents. By default,
+// input documents without joined documents are kept (i.e. a left join).
+func (jb *JoinBuilder) Inner() *JoinBuilder {
+	jb.inner = true
+	return jb
+}
+
+func (jb *JoinBuilder) Build() (Pipeline, error) {
+	if jb.from == "" {
+		return nil, errors.New("join collection must not be empty")
+	}
+	if (jb.localField == "") != (jb.foreignField == "") {
+		return nil, errors.New("join must set both LocalField and ForeignField or neither")
+	}
+	if jb.localField == "" && jb.pipeline == nil {
+		return nil, errors.New("join must set LocalField and ForeignField or Pipeline")
+	}
+	if len(jb.let) > 0 && jb.pipeline == nil {
+		return nil, errors.New("join Let variables require a Pipeline")
+	}
+	if err := jb.checkVars(); err != nil {
+		return nil, err
+	}
+
+	as := jb.as
+	if as == "" {
+		as = jb.from
+	}
+
+	lookup := make(bson.D, 0, 6)
+	lookup = append(lookup, bson.E{Key: "from", Value: jb.from})
+	if jb.localField != "" {
+		lookup = append(lookup,
+			bson.E{Key: "localField", Value: jb.localField},
+			bson.E{Key: "foreignField", Value: jb.foreignField})
+	}
+	if len(jb.let) > 0 {
+		lookup = append(lookup, bson.E{Key: "let", Value: fieldExprsToD(jb.let)})
+	}
+	if jb.pipeline != nil {
+		lookup = append(lookup, bson.E{Key: "pipeline", Value: jb.pipeline.Clone()})
+	}
+	lookup = append(lookup, bson.E{Key: "as", Value: as})
+
+	stages := Pipeline{{{Key: "$lookup", Value: lookup}}}
+	switch {
+	case jb.unwindOne:
+		stages = append(stages, Stage{{
+			Key: "$unwind",
+			Value: bson.D{
+				{Key: "path", Value: "$" + as},
+				{Key: "preserveNullAndEmptyArrays", Value: !jb.inner},
+			},
+		}})
+	case jb.inner:
+		stages = append(stages, Match(bson.D{{
+			Key:   as,
+			Value: bson.D{{Key: "$ne", Value: bson.A{}}},
+		}}))
+	}
+	return stages, nil
+}
+
+// checkVars returns an error if the pipeline uses a Var that isn't declared in
+// the join's let variables.
+func (jb *JoinBuilder) checkVars() error {
+	declared := make(map[string]bool, len(jb.let))
+	for _, f := range jb.let {
+		declared[f.Key] = true
+	}
+	var err error
+	walkValue(jb.pipeline, func(v any) bool {
+		if ref, ok := v.(Var); ok && !declared[ref.name] && err == nil {
+			err = fmt.Errorf("join pipeline references undeclared variable %q", ref.name)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// Var is a reference to a $lookup let variable, declared with JoinBuilder.Var.
+// It marshals to a "$$<name>" string.
+type Var struct {
+	name string
+	path string
+}
+
+// Name returns the variable name.
+func (v Var) Name() string {
+	return v.name
+}
+
+// Field returns a reference to a field of the variable's value, like
+// "$$order.items".
+func (v Var) Field(path string) Var {
+	if v.path != "" {
+		path = v.path + "." + path
+	}
+	return Var{name: v.name, path: path}
+}
+
+func (v Var) String() string {
+	if v.path == "" {
+		return "$$" + v.name
+	}
+	return "$$" + v.name + "." + v.path
+}
+
+func (v Var) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(v.String())
+}
+
+// InnerJoin returns a $lookup of the documents in the "from" collection whose
+// foreignField matches localField, followed by an $unwind of the "as" field.
+// Each output document has one joined document in "as", and input documents
+// without a match are dropped. Input documents with multiple matches are
+// output once per match.
+func InnerJoin(from, localField, foreignField, as string) Pipeline {
+	return Pipeline{
+		Lookup(from, localField, foreignField, as),
+		Unwind("$" + as),
+	}
+}
+
+// LeftJoin is like InnerJoin, but keeps input documents without a match, with
+// the "as" field missing.
+func LeftJoin(from, localField, foreignField, as string) Pipeline {
+	return Pipeline{
+		Lookup(from, localField, foreignField, as),
+		Unwind("$"+as, WithPreserveNullAndEmptyArrays()),
+	}
+}
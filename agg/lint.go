@@ -0,0 +1,434 @@
+package agg
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Lint rules reported in LintWarning.Rule.
+const (
+	RuleMatchDroppedField  = "match-dropped-field"
+	RuleSortWithoutMatch   = "sort-without-match"
+	RuleLookupBeforeLimit  = "lookup-before-limit"
+	RuleUnusedAddFields    = "unused-add-fields"
+	RuleUnboundedFacet     = "unbounded-facet"
+	RuleOutputStageNotLast = "output-stage-not-last"
+	RuleFirstStageNotFirst = "first-stage-not-first"
+)
+
+// LintWarning describes a likely mistake or inefficiency in a pipeline.
+type LintWarning struct {
+	// StageIndex is the index of the stage the warning is about.
+	StageIndex int
+	StageName  string
+	Rule       string
+	Message    string
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("stage %d (%s): %s [%s]", w.StageIndex, w.StageName, w.Message, w.Rule)
+}
+
+// Lint checks the pipeline for well-known anti-patterns:
+//
+//   - A $match on a field that a preceding $project or $unset removed, so
+//     the $match can never match on it.
+//   - A $sort with no preceding $match or other stage that reduces the
+//     number of documents, which sorts the whole collection unless an index
+//     supports the sort.
+//   - A $lookup followed by a $limit, where the $limit could be moved before
+//     the $lookup to run fewer lookups.
+//   - $addFields or $set outputs that are removed or overwritten before any
+//     stage reads them.
+//   - $facet pipelines without a $limit, $group, or $count, which can exceed
+//     the 16MB document limit because all facet results are returned in a
+//     single document.
+//   - $out or $merge stages that aren't the last stage.
+//   - Stages that must be first, like $geoNear and $search, that aren't the
+//     first stage.
+//
+// Lint only analyzes stages built as documents (e.g. not RawStage stages)
+// and can't know the shape of the input documents, so it may miss problems.
+func Lint(p Pipeline) []LintWarning {
+	var warnings []LintWarning
+	warn := func(i int, rule, format string, args ...any) {
+		warnings = append(warnings, LintWarning{
+			StageIndex: i,
+			StageName:  stageName(p[i]),
+			Rule:       rule,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	shape := newFieldShape()
+	reduced := false
+	for i, s := range p {
+		name := stageName(s)
+		body := stageBody(s)
+
+		switch name {
+		case "$match":
+			for _, f := range filterFields(body) {
+				if !shape.available(f) {
+					warn(i, RuleMatchDroppedField,
+						"matches on field %q, which a previous stage removed", f)
+				}
+			}
+		case "$sort":
+			if !reduced {
+				warn(i, RuleSortWithoutMatch,
+					"sorts all input documents; add a preceding $match or make sure an index supports the sort")
+			}
+		case "$lookup":
+			if j, ok := limitAfterLookup(p, i); ok {
+				warn(i, RuleLookupBeforeLimit,
+					"is followed by a $limit at stage %d, which could be moved before the $lookup", j)
+			}
+		case "$addFields", "$set":
+			if d, ok := docElems(body); ok {
+				for _, e := range d {
+					if j, ok := unusedField(p, i, e.Key); ok {
+						warn(i, RuleUnusedAddFields,
+							"adds field %q, which stage %d removes or overwrites before it's used", e.Key, j)
+					}
+				}
+			}
+		case "$facet":
+			if d, ok := docElems(body); ok {
+				for _, e := range d {
+					sub, ok := toPipeline(e.Value)
+					if ok && !hasStage(sub, "$limit", "$group", "$count", "$bucket", "$bucketAuto", "$sortByCount", "$sample") {
+						warn(i, RuleUnboundedFacet,
+							"facet %q may return all input documents, which can exceed the 16MB result document limit",
+							e.Key)
+					}
+				}
+			}
+		case "$out", "$merge":
+			if i != len(p)-1 {
+				warn(i, RuleOutputStageNotLast, "must be the last stage in the pipeline")
+			}
+		}
+		if info, ok := LookupStage(name); ok && info.First && i != 0 {
+			warn(i, RuleFirstStageNotFirst, "must be the first stage in the pipeline")
+		}
+
+		switch name {
+		case "$match", "$geoNear", "$search", "$vectorSearch", "$limit", "$sample", "$group",
+			"$bucket", "$bucketAuto", "$sortByCount", "$count", "$facet":
+			reduced = true
+		}
+		shape.apply(s)
+	}
+	return warnings
+}
+
+func hasStage(p Pipeline, names ...string) bool {
+	for _, s := range p {
+		for _, name := range names {
+			if stageName(s) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// limitAfterLookup returns the index of a $limit stage after the $lookup at
+// index i that could be moved before it.
+func limitAfterLookup(p Pipeline, i int) (int, bool) {
+	for j := i + 1; j < len(p); j++ {
+		switch stageName(p[j]) {
+		case "$limit":
+			return j, true
+		case "$lookup", "$addFields", "$set", "$project", "$unset", "$replaceRoot", "$replaceWith":
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// unusedField returns the index of the stage that removes or overwrites the
+// field added at stage i before any stage reads it.
+func unusedField(p Pipeline, i int, field string) (int, bool) {
+	for j := i + 1; j < len(p); j++ {
+		s := p[j]
+		for _, ref := range stageFieldRefs(s) {
+			if pathsOverlap(ref, field) {
+				return 0, false
+			}
+		}
+		// Conservatively assume sub-pipelines read every field.
+		if len(stageSubPipelines(s)) > 0 {
+			return 0, false
+		}
+
+		body := stageBody(s)
+		switch stageName(s) {
+		case "$unset":
+			for _, f := range unsetFields(body) {
+				if f == field || strings.HasPrefix(field, f+".") {
+					return j, true
+				}
+			}
+		case "$project":
+			inclusion, fields := projection(body)
+			kept := !inclusion
+			for f := range fields {
+				if inclusion && pathsOverlap(f, field) {
+					kept = true
+				}
+				if !inclusion && (f == field || strings.HasPrefix(field, f+".")) {
+					kept = false
+				}
+			}
+			if !kept {
+				return j, true
+			}
+		case "$addFields", "$set":
+			if _, ok := lookupKey(body, field); ok {
+				return j, true
+			}
+		case "$group", "$count", "$replaceRoot", "$replaceWith", "$bucket", "$bucketAuto", "$sortByCount":
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+// pathsOverlap returns true if either path is the same as or a subfield of
+// the other.
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+func unsetFields(body any) []string {
+	switch v := body.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	}
+	var fields []string
+	walkValue(body, func(v any) bool {
+		if s, ok := v.(string); ok {
+			fields = append(fields, s)
+		}
+		return true
+	})
+	return fields
+}
+
+// projection returns whether the $project specification is an inclusion
+// projection and the fields it includes or excludes, with fields in nested
+// specifications like {a: {b: 1}} as dotted paths. The "_id" field is only
+// returned if it's excluded in an inclusion projection.
+func projection(body any) (bool, map[string]bool) {
+	spec := projectionSpec(body)
+	inclusion := false
+	for _, e := range spec {
+		if e.Key != "_id" && (!isProjectionFlag(e.Value) || !isFalsy(e.Value)) {
+			inclusion = true
+		}
+	}
+
+	fields := make(map[string]bool, len(spec))
+	for _, e := range spec {
+		switch {
+		case e.Key == "_id" && inclusion:
+			if isFalsy(e.Value) {
+				fields["_id"] = false
+			}
+		case inclusion == !isFalsy(e.Value):
+			fields[e.Key] = true
+		}
+	}
+	return inclusion, fields
+}
+
+// projectionSpec returns the fields of a $project specification with nested
+// specifications flattened into dotted paths, so {a: {b: 1, c: "$x"}} returns
+// {"a.b": 1, "a.c": "$x"}.
+func projectionSpec(body any) bson.D {
+	var spec bson.D
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		d, _ := docElems(v)
+		for _, e := range d {
+			path := e.Key
+			if prefix != "" {
+				path = prefix + "." + e.Key
+			}
+			if isNestedProjection(e.Value) {
+				walk(path, e.Value)
+				continue
+			}
+			spec = append(spec, bson.E{Key: path, Value: e.Value})
+		}
+	}
+	walk("", body)
+	return spec
+}
+
+// isNestedProjection returns true if v is a non-empty document with no
+// operator keys, like {b: 1} in the $project specification {a: {b: 1}}.
+func isNestedProjection(v any) bool {
+	d, ok := docElems(v)
+	if !ok || len(d) == 0 {
+		return false
+	}
+	for _, e := range d {
+		if strings.HasPrefix(e.Key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// isProjectionFlag returns true if v includes or excludes a field in a
+// $project specification, rather than computing it.
+func isProjectionFlag(v any) bool {
+	switch v.(type) {
+	case bool, int, int32, int64, float64:
+		return true
+	}
+	return false
+}
+
+func isFalsy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case int32:
+		return v == 0
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	}
+	return false
+}
+
+// fieldShape tracks which top-level fields are available to a stage based on
+// the preceding stages.
+type fieldShape struct {
+	// only is the set of available fields, or nil if any field may be
+	// available.
+	only map[string]bool
+	// dropped is the set of fields that are not available.
+	dropped map[string]bool
+}
+
+func newFieldShape() *fieldShape {
+	return &fieldShape{dropped: make(map[string]bool)}
+}
+
+func (fs *fieldShape) available(path string) bool {
+	top := topField(path)
+	if fs.dropped[top] {
+		return false
+	}
+	return fs.only == nil || fs.only[top]
+}
+
+func (fs *fieldShape) add(path string) {
+	top := topField(path)
+	delete(fs.dropped, top)
+	if fs.only != nil {
+		fs.only[top] = true
+	}
+}
+
+func (fs *fieldShape) apply(s Stage) {
+	body := stageBody(s)
+	switch stageName(s) {
+	case "$project":
+		inclusion, fields := projection(body)
+		if !inclusion {
+			for f := range fields {
+				if !strings.Contains(f, ".") {
+					fs.dropped[f] = true
+				}
+			}
+			return
+		}
+		fs.only = map[string]bool{"_id": true}
+		fs.dropped = make(map[string]bool)
+		for f, included := range fields {
+			if included {
+				fs.only[topField(f)] = true
+			} else {
+				delete(fs.only, f)
+			}
+		}
+	case "$unset":
+		for _, f := range unsetFields(body) {
+			if !strings.Contains(f, ".") {
+				fs.dropped[f] = true
+			}
+		}
+	case "$addFields", "$set":
+		if d, ok := docElems(body); ok {
+			for _, e := range d {
+				fs.add(e.Key)
+			}
+		}
+	case "$lookup", "$graphLookup":
+		if as, ok := lookupKey(body, "as"); ok {
+			if as, ok := as.(string); ok {
+				fs.add(as)
+			}
+		}
+	case "$group":
+		fs.only = map[string]bool{}
+		fs.dropped = make(map[string]bool)
+		if d, ok := docElems(body); ok {
+			for _, e := range d {
+				fs.only[e.Key] = true
+			}
+		}
+	case "$count":
+		fs.dropped = make(map[string]bool)
+		fs.only = map[string]bool{}
+		if f, ok := body.(string); ok {
+			fs.only[f] = true
+		}
+	case "$setWindowFields":
+		if out, ok := lookupKey(body, "output"); ok {
+			if d, ok := docElems(out); ok {
+				for _, e := range d {
+					fs.add(e.Key)
+				}
+			}
+		}
+	case "$unwind", "$geoNear", "$densify":
+		// These stages don't remove fields, but can add the fields named by
+		// these options.
+		for _, key := range []string{"includeArrayIndex", "distanceField", "includeLocs", "field"} {
+			if f, ok := lookupKey(body, key); ok {
+				if f, ok := f.(string); ok {
+					fs.add(f)
+				}
+			}
+		}
+	case "$fill":
+		if out, ok := lookupKey(body, "output"); ok {
+			if d, ok := docElems(out); ok {
+				for _, e := range d {
+					fs.add(e.Key)
+				}
+			}
+		}
+	case "$match", "$sort", "$limit", "$skip", "$sample", "$redact", "$unionWith":
+		// These stages don't add or remove fields.
+	default:
+		// The output shape is unknown.
+		fs.only = nil
+		fs.dropped = make(map[string]bool)
+	}
+}
@@ -0,0 +1,69 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLintFirstStageNotFirst(t *testing.T) {
+	near := GeoNear(bson.D{}, "dist")
+
+	tests := []struct {
+		name string
+		p    Pipeline
+		want []int
+	}{
+		{name: "first", p: Pipeline{near, Limit(1)}},
+		{name: "named", p: Named("x", near, Limit(1))},
+		{name: "after comment", p: Pipeline{CommentStage("x"), near}, want: []int{1}},
+		{name: "after $limit", p: Pipeline{Limit(1), Stage{{Key: "$search", Value: bson.D{}}}}, want: []int{1}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int
+			for _, w := range Lint(tc.p) {
+				if w.Rule == RuleFirstStageNotFirst {
+					got = append(got, w.StageIndex)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got warnings at stages %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLintMatchDroppedField(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage Stage
+		field string
+		want  bool
+	}{
+		{name: "dropped field", stage: Limit(1), field: "b", want: true},
+		{name: "$unwind includeArrayIndex", stage: Unwind("$a", WithIncludeArrayIndex("idx")), field: "idx"},
+		{name: "$geoNear distanceField", stage: GeoNear(bson.D{}, "dist"), field: "dist"},
+		{name: "$densify field", stage: Densify("ts", nil, DensifyRange{Step: 1, Bounds: "full"}), field: "ts"},
+		{name: "$fill output", stage: Fill(nil, nil, Field("qty", FillValue(0))), field: "qty"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Pipeline{
+				Project(Field("a", 1)),
+				tc.stage,
+				Match(bson.D{{Key: tc.field, Value: 0}}),
+			}
+			got := false
+			for _, w := range Lint(p) {
+				if w.Rule == RuleMatchDroppedField {
+					got = true
+				}
+			}
+			if got != tc.want {
+				t.Errorf("got warning %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package agg
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaterializedView describes an on-demand materialized view: a collection
+// that holds the results of a pipeline and is incrementally refreshed with
+// $merge.
+type MaterializedView struct {
+	// Into is the view collection name, or a {db, coll} document.
+	Into any
+
+	// On are the fields that uniquely identify a view document. Defaults to
+	// "_id". Fields other than "_id" must have a unique index.
+	On []string
+
+	// WhenMatched is the $merge action for results that match an existing
+	// view document: "merge", "replace", "keepExisting", "fail", or an update
+	// pipeline. Defaults to "merge".
+	WhenMatched any
+
+	// WhenNotMatched is the $merge action for results that don't match an
+	// existing view document: "insert", "discard", or "fail". Defaults to
+	// "insert".
+	WhenNotMatched string
+
+	// WatermarkField is a source collection field that increases when a
+	// document changes, like a last-modified date. If set, each refresh only
+	// reads source documents with a watermark greater than the previous
+	// refresh's.
+	WatermarkField string
+
+	// Collation is the collation used by the refresh pipeline, if not nil.
+	Collation *options.Collation
+
+	// Exec configures the refresh aggregate command.
+	Exec []ExecOption
+}
+
+// Pipeline returns the refresh pipeline. If WatermarkField is set and since
+// is not nil, the pipeline starts with a $match for source documents where
+// the watermark field is greater than since.
+func (mv MaterializedView) Pipeline(pipeline Pipeline, since any) Pipeline {
+	p := make(Pipeline, 0, len(pipeline)+2)
+	if mv.WatermarkField != "" && since != nil {
+		p = append(p, Match(bson.D{{
+			Key:   mv.WatermarkField,
+			Value: bson.D{{Key: "$gt", Value: since}},
+		}}))
+	}
+	p = append(p, pipeline...)
+
+	var opts []MergeOption
+	if len(mv.On) > 0 {
+		opts = append(opts, WithMergeOn(mv.On...))
+	}
+	if mv.WhenMatched != nil && mv.WhenMatched != "" {
+		opts = append(opts, WithWhenMatched(mv.WhenMatched))
+	}
+	if mv.WhenNotMatched != "" {
+		opts = append(opts, WithWhenNotMatched(mv.WhenNotMatched))
+	}
+	return append(p, Merge(mv.Into, opts...))
+}
+
+// Refresh runs the refresh pipeline against the source collection.
+func (mv MaterializedView) Refresh(
+	ctx context.Context,
+	source *mongo.Collection,
+	pipeline Pipeline,
+	since any,
+) error {
+	cur, err := Aggregate(ctx, source, mv.Pipeline(pipeline, since), execOptions(mv.Collation, mv.Exec)...)
+	if err != nil {
+		return err
+	}
+	return cur.Close(ctx)
+}
@@ -0,0 +1,112 @@
+package agg
+
+import (
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Normalize returns a copy of v with every map with string keys (like bson.M)
+// converted to a bson.D sorted by key, recursively. Go randomizes map
+// iteration order, so pipelines built with maps marshal to different bytes
+// each time; normalized values always marshal the same way, which makes
+// pipelines usable in golden-file tests and as cache keys.
+func Normalize(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case bson.D:
+		return normalizeD(v)
+	case Operator:
+		return Operator(normalizeD(bson.D(v)))
+	case BoolExpr:
+		return BoolExpr{expr: Normalize(v.expr)}
+	case FieldExpr:
+		return FieldExpr{Key: v.Key, Value: Normalize(v.Value)}
+	case SortBy:
+		return SortBy{Key: v.Key, Value: Normalize(v.Value)}
+	case bson.E:
+		return bson.E{Key: v.Key, Value: Normalize(v.Value)}
+	case bson.A:
+		return normalizeA(v)
+	case []any:
+		return normalizeA(v)
+	case Pipeline:
+		return v.Normalize()
+	case []bson.D:
+		return Pipeline(v).Normalize()
+	case bson.M:
+		return normalizeMap(reflect.ValueOf(v))
+	case string, []byte, []string, bson.Raw, bson.RawValue:
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			return normalizeMap(rv)
+		}
+	case reflect.Slice:
+		if rv.IsNil() {
+			return v
+		}
+		if rv.Type().Elem().Kind() == reflect.Map {
+			a := make(bson.A, rv.Len())
+			for i := range a {
+				a[i] = Normalize(rv.Index(i).Interface())
+			}
+			return a
+		}
+	}
+	return v
+}
+
+// Normalize returns a copy of the pipeline with all maps converted to sorted
+// bson.D documents. See Normalize.
+func (p Pipeline) Normalize() Pipeline {
+	if p == nil {
+		return nil
+	}
+	n := make(Pipeline, len(p))
+	for i := range p {
+		n[i] = normalizeD(p[i])
+	}
+	return n
+}
+
+func normalizeD(d bson.D) bson.D {
+	if d == nil {
+		return nil
+	}
+	n := make(bson.D, len(d))
+	for i := range d {
+		n[i] = bson.E{Key: d[i].Key, Value: Normalize(d[i].Value)}
+	}
+	return n
+}
+
+func normalizeA(a []any) bson.A {
+	if a == nil {
+		return nil
+	}
+	n := make(bson.A, len(a))
+	for i := range a {
+		n[i] = Normalize(a[i])
+	}
+	return n
+}
+
+func normalizeMap(rv reflect.Value) bson.D {
+	if rv.IsNil() {
+		return nil
+	}
+	d := make(bson.D, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		d = append(d, bson.E{Key: iter.Key().String(), Value: Normalize(iter.Value().Interface())})
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i].Key < d[j].Key })
+	return d
+}
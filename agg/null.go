@@ -0,0 +1,40 @@
+package agg
+
+import "github.com/matthewdale/mongo-go-exp/query"
+
+// Coalesce returns the first expression that doesn't evaluate to null or a
+// missing field, or the last expression if all of them do. It's built from
+// nested two-argument $ifNull expressions, so it works on all server versions.
+func Coalesce(exprs ...any) any {
+	switch len(exprs) {
+	case 0:
+		return nil
+	case 1:
+		return exprs[0]
+	}
+
+	expr := exprs[len(exprs)-1]
+	for i := len(exprs) - 2; i >= 0; i-- {
+		expr = IfNull(exprs[i], expr)
+	}
+	return expr
+}
+
+// DefaultTo returns the value of the field, or value if the field is null or
+// missing. The field is prefixed with '$' if it isn't already.
+func DefaultTo(field string, value any) Operator {
+	return IfNull(fieldPathValue(field), value)
+}
+
+// IsMissing returns an expression that is true if the field doesn't exist.
+// Unlike comparing to null, it's false for fields that exist with a null
+// value. The field is prefixed with '$' if it isn't already.
+func IsMissing(field string) Operator {
+	return IsType(fieldPathValue(field), query.TypeMissing)
+}
+
+// IsNullOrMissing returns an expression that is true if the field doesn't
+// exist or is null. The field is prefixed with '$' if it isn't already.
+func IsNullOrMissing(field string) Operator {
+	return IsType(fieldPathValue(field), query.TypeMissing, query.TypeNull)
+}
@@ -1,6 +1,9 @@
 package agg
 
-import "go.mongodb.org/mongo-driver/bson"
+import (
+	"github.com/matthewdale/mongo-go-exp/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
 
 type Operator bson.D
 
@@ -8,6 +11,47 @@ func Abs(numExpr any) Operator {
 	return Operator{{Key: "$abs", Value: numExpr}}
 }
 
+func Add(exprs ...any) Operator {
+	return Operator{{
+		Key:   "$add",
+		Value: exprsToA(exprs),
+	}}
+}
+
+func And(exprs ...any) Operator {
+	return Operator{{
+		Key:   "$and",
+		Value: exprsToA(exprs),
+	}}
+}
+
+func Avg(exprs ...any) Operator {
+	var body any
+	if len(exprs) == 1 {
+		body = exprs[0]
+	} else {
+		body = exprsToA(exprs)
+	}
+
+	return Operator{{
+		Key:   "$avg",
+		Value: body,
+	}}
+}
+
+// ArrayElemAt returns the element of arrayExpr at index idxExpr. Negative
+// indexes count back from the end of the array.
+func ArrayElemAt(arrayExpr, idxExpr any) Operator {
+	return Operator{{Key: "$arrayElemAt", Value: bson.A{arrayExpr, idxExpr}}}
+}
+
+// ArrayToObject converts arrExpr, an array of {k, v} documents or [key, value]
+// pairs, to a document.
+func ArrayToObject(arrExpr any) Operator {
+	return Operator{{Key: "$arrayToObject", Value: arrExpr}}
+}
+
+// Deprecated: Use acc.Bottom instead.
 func Bottom(outputExpr any, sortBys ...SortBy) Operator {
 	return Operator{{
 		Key: "$bottom",
@@ -21,10 +65,12 @@ func Bottom(outputExpr any, sortBys ...SortBy) Operator {
 	}}
 }
 
+// Deprecated: Use acc.BottomN instead.
 func BottomN(outputExpr any, n int64, sortBys ...SortBy) Operator {
 	return BottomNExpr(outputExpr, n, sortBys...)
 }
 
+// Deprecated: Use acc.BottomNExpr instead.
 func BottomNExpr(outputExpr, nExpr any, sortBys ...SortBy) Operator {
 	return Operator{{
 		Key: "$bottomN",
@@ -41,6 +87,13 @@ func BottomNExpr(outputExpr, nExpr any, sortBys ...SortBy) Operator {
 	}}
 }
 
+func Concat(exprs ...any) Operator {
+	return Operator{{
+		Key:   "$concat",
+		Value: exprsToA(exprs),
+	}}
+}
+
 func Cond(ifExpr, thenExpr, elseExpr any) Operator {
 	return Operator{{
 		Key: "$cond",
@@ -57,6 +110,26 @@ func Cond(ifExpr, thenExpr, elseExpr any) Operator {
 	}}
 }
 
+// Convert converts inputExpr to the type to. The onErrorExpr and onNullExpr
+// values are returned if the conversion fails or the input is null or
+// missing, and are omitted if nil.
+func Convert(inputExpr any, to query.BSONType, onErrorExpr, onNullExpr any) Operator {
+	body := make(bson.D, 2, 4)
+	body[0] = bson.E{Key: "input", Value: inputExpr}
+	body[1] = bson.E{Key: "to", Value: to}
+	if onErrorExpr != nil {
+		body = append(body, bson.E{Key: "onError", Value: onErrorExpr})
+	}
+	if onNullExpr != nil {
+		body = append(body, bson.E{Key: "onNull", Value: onNullExpr})
+	}
+
+	return Operator{{
+		Key:   "$convert",
+		Value: body,
+	}}
+}
+
 func Divide(numeratorExpr, denomExpr any) Operator {
 	return Operator{{
 		Key:   "$divide",
@@ -73,13 +146,9 @@ func Eq(expr1, expr2 any) Operator {
 
 // TODO: Make a different func for including limit, or pass nil?
 func Filter(inputExpr any, as string, condExpr, limitExpr any) Operator {
-	body := bson.D{{
-		Key:   "input",
-		Value: inputExpr,
-	}, {
-		Key:   "cond",
-		Value: condExpr,
-	}}
+	body := make(bson.D, 2, 4)
+	body[0] = bson.E{Key: "input", Value: inputExpr}
+	body[1] = bson.E{Key: "cond", Value: condExpr}
 	if len(as) > 0 {
 		body = append(body, bson.E{Key: "as", Value: as})
 	}
@@ -93,6 +162,43 @@ func Filter(inputExpr any, as string, condExpr, limitExpr any) Operator {
 	}}
 }
 
+// GetField returns the value of field in the document inputExpr. Unlike a
+// field path, field can contain '.' and start with '$' (wrap such names in
+// Literal). If inputExpr is nil, $$CURRENT is used.
+func GetField(inputExpr, fieldExpr any) Operator {
+	body := make(bson.D, 1, 2)
+	body[0] = bson.E{Key: "field", Value: fieldExpr}
+	if inputExpr != nil {
+		body = append(body, bson.E{Key: "input", Value: inputExpr})
+	}
+
+	return Operator{{
+		Key:   "$getField",
+		Value: body,
+	}}
+}
+
+func Gt(expr1, expr2 any) Operator {
+	return Operator{{
+		Key:   "$gt",
+		Value: bson.A{expr1, expr2},
+	}}
+}
+
+func Gte(expr1, expr2 any) Operator {
+	return Operator{{
+		Key:   "$gte",
+		Value: bson.A{expr1, expr2},
+	}}
+}
+
+func IfNull(exprs ...any) Operator {
+	return Operator{{
+		Key:   "$ifNull",
+		Value: exprsToA(exprs),
+	}}
+}
+
 func In(targetExpr, arrExpr any) Operator {
 	return Operator{{
 		Key:   "$in",
@@ -100,11 +206,35 @@ func In(targetExpr, arrExpr any) Operator {
 	}}
 }
 
-func Map(inputExpr any, as string, inExpr any) Operator {
-	body := bson.D{{
-		Key:   "input",
-		Value: inputExpr,
+func Lt(expr1, expr2 any) Operator {
+	return Operator{{
+		Key:   "$lt",
+		Value: bson.A{expr1, expr2},
+	}}
+}
+
+func Lte(expr1, expr2 any) Operator {
+	return Operator{{
+		Key:   "$lte",
+		Value: bson.A{expr1, expr2},
 	}}
+}
+
+// IsType returns true if expr is any of the given BSON types.
+func IsType(expr any, types ...query.BSONType) Operator {
+	if len(types) == 1 {
+		return Eq(Type(expr), types[0])
+	}
+	return In(Type(expr), append([]query.BSONType(nil), types...))
+}
+
+func Literal(value any) Operator {
+	return Operator{{Key: "$literal", Value: value}}
+}
+
+func Map(inputExpr any, as string, inExpr any) Operator {
+	body := make(bson.D, 1, 3)
+	body[0] = bson.E{Key: "input", Value: inputExpr}
 	if len(as) > 0 {
 		body = append(body, bson.E{Key: "as", Value: as})
 	}
@@ -123,7 +253,7 @@ func Max(exprs ...any) Operator {
 	if len(exprs) == 1 {
 		body = exprs[0]
 	} else {
-		body = bson.A(exprs)
+		body = exprsToA(exprs)
 	}
 
 	return Operator{{
@@ -138,7 +268,7 @@ func Min(exprs ...any) Operator {
 	if len(exprs) == 1 {
 		body = exprs[0]
 	} else {
-		body = bson.A(exprs)
+		body = exprsToA(exprs)
 	}
 
 	return Operator{{
@@ -147,13 +277,17 @@ func Min(exprs ...any) Operator {
 	}}
 }
 
+func Meta(keyword string) Operator {
+	return Operator{{Key: "$meta", Value: keyword}}
+}
+
 func MergeObjects(documentExprs ...any) Operator {
 	var body any
 	// TODO: Why?
 	if len(documentExprs) == 1 {
 		body = documentExprs[0]
 	} else {
-		body = bson.A(documentExprs)
+		body = exprsToA(documentExprs)
 	}
 
 	return Operator{{
@@ -169,10 +303,17 @@ func Ne(expr1, expr2 any) Operator {
 	}}
 }
 
+func Not(expr any) Operator {
+	return Operator{{
+		Key:   "$not",
+		Value: bson.A{expr},
+	}}
+}
+
 func Or(exprs ...any) Operator {
 	return Operator{{
 		Key:   "$or",
-		Value: bson.A(exprs),
+		Value: exprsToA(exprs),
 	}}
 }
 
@@ -192,10 +333,90 @@ func Reduce(inputExpr, initialValueExpr, inExpr any) Operator {
 	}}
 }
 
+// SetField returns a copy of the document inputExpr with field set to
+// valueExpr. Like GetField, field can contain '.' and start with '$'.
+func SetField(inputExpr, fieldExpr, valueExpr any) Operator {
+	return Operator{{
+		Key: "$setField",
+		Value: bson.D{
+			{Key: "field", Value: fieldExpr},
+			{Key: "input", Value: inputExpr},
+			{Key: "value", Value: valueExpr},
+		},
+	}}
+}
+
+// Sigmoid returns 1 / (1 + e^-x) for the number expr, scaling it to the range
+// (0, 1). It's useful for normalizing search scores. Requires MongoDB 8.2.
+func Sigmoid(expr any) Operator {
+	return Operator{{Key: "$sigmoid", Value: expr}}
+}
+
+func Size(arrExpr any) Operator {
+	return Operator{{Key: "$size", Value: arrExpr}}
+}
+
+func Split(strExpr, delimiterExpr any) Operator {
+	return Operator{{
+		Key:   "$split",
+		Value: bson.A{strExpr, delimiterExpr},
+	}}
+}
+
+// SortArray sorts the array inputExpr by sortBy, which is 1 or -1 to sort by
+// the element values, or a document like {score: -1} to sort documents by
+// their fields. Strings are compared using the aggregate collation.
+func SortArray(inputExpr, sortBy any) Operator {
+	return Operator{{
+		Key: "$sortArray",
+		Value: bson.D{
+			{Key: "input", Value: inputExpr},
+			{Key: "sortBy", Value: sortBy},
+		},
+	}}
+}
+
+// StrCaseCmp returns 0 if the strings expr1 and expr2 are equal ignoring ASCII
+// case, 1 if expr1 is greater, and -1 if it's less.
+func StrCaseCmp(expr1, expr2 any) Operator {
+	return Operator{{
+		Key:   "$strcasecmp",
+		Value: bson.A{expr1, expr2},
+	}}
+}
+
 func Sum(numExpr any) Operator {
 	return Operator{{Key: "$sum", Value: numExpr}}
 }
 
+func ToDecimal(expr any) Operator {
+	return Operator{{Key: "$toDecimal", Value: expr}}
+}
+
+// ToHashedIndexKey returns the hash of expr used by hashed indexes.
+func ToHashedIndexKey(expr any) Operator {
+	return Operator{{Key: "$toHashedIndexKey", Value: expr}}
+}
+
+func ToLower(expr any) Operator {
+	return Operator{{Key: "$toLower", Value: expr}}
+}
+
+func ToString(expr any) Operator {
+	return Operator{{Key: "$toString", Value: expr}}
+}
+
+// ToUUID converts a UUID string expression to a BSON UUID (binary subtype 4).
+// Requires MongoDB 8.0.
+func ToUUID(expr any) Operator {
+	return Operator{{Key: "$toUUID", Value: expr}}
+}
+
+func ToUpper(expr any) Operator {
+	return Operator{{Key: "$toUpper", Value: expr}}
+}
+
+// Deprecated: Use acc.Top instead.
 func Top(outputExpr any, sortBy ...SortBy) Operator {
 	return Operator{{
 		Key: "$top",
@@ -209,10 +430,12 @@ func Top(outputExpr any, sortBy ...SortBy) Operator {
 	}}
 }
 
+// Deprecated: Use acc.TopN instead.
 func TopN(outputExpr, n int64, sortBy ...SortBy) Operator {
 	return TopNExpr(outputExpr, n, sortBy...)
 }
 
+// Deprecated: Use acc.TopNExpr instead.
 func TopNExpr(outputExpr, nExpr any, sortBy ...SortBy) Operator {
 	return Operator{{
 		Key: "$topN",
@@ -228,3 +451,15 @@ func TopNExpr(outputExpr, nExpr any, sortBy ...SortBy) Operator {
 		}},
 	}}
 }
+
+// Trim removes whitespace from the beginning and end of the string inputExpr.
+func Trim(inputExpr any) Operator {
+	return Operator{{
+		Key:   "$trim",
+		Value: bson.D{{Key: "input", Value: inputExpr}},
+	}}
+}
+
+func Type(expr any) Operator {
+	return Operator{{Key: "$type", Value: expr}}
+}
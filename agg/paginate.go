@@ -0,0 +1,155 @@
+package agg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Page is a page of results decoded as T.
+type Page[T any] struct {
+	Items []T
+
+	// Number is the page number, starting at 1.
+	Number   int64
+	PageSize int64
+
+	// HasMore is true if there are results after this page.
+	HasMore bool
+
+	// TotalCount is the total number of results across all pages. It's only
+	// set by Paginator.PageWithTotal, and is -1 otherwise.
+	TotalCount int64
+}
+
+// Paginator pages through the results of a base pipeline. The base pipeline
+// should end with a $sort on a unique field (or combination of fields) so
+// pages are stable across requests.
+type Paginator[T any] struct {
+	Collection *mongo.Collection
+	Pipeline   Pipeline
+	PageSize   int64
+
+	// Options are passed to every aggregate command the paginator runs.
+	Options []*options.AggregateOptions
+
+	// Exec configures every aggregate command the paginator runs. It's
+	// applied after Options.
+	Exec []ExecOption
+}
+
+func (pg Paginator[T]) aggregateOptions() []*options.AggregateOptions {
+	if len(pg.Exec) == 0 {
+		return pg.Options
+	}
+	opts := make([]*options.AggregateOptions, 0, len(pg.Options)+1)
+	opts = append(opts, pg.Options...)
+	return append(opts, AggregateOptions(pg.Exec...))
+}
+
+func (pg Paginator[T]) skip(page int64) (int64, error) {
+	if pg.PageSize < 1 {
+		return 0, fmt.Errorf("page size must be at least 1, got %d", pg.PageSize)
+	}
+	if page < 1 {
+		return 0, fmt.Errorf("page number must be at least 1, got %d", page)
+	}
+	return (page - 1) * pg.PageSize, nil
+}
+
+// PagePipeline returns the pipeline that fetches the given page with $skip and
+// $limit. It fetches one extra document to detect whether there are more
+// pages.
+func (pg Paginator[T]) PagePipeline(page int64) (Pipeline, error) {
+	skip, err := pg.skip(page)
+	if err != nil {
+		return nil, err
+	}
+	return pg.Pipeline.Append(Skip(skip), Limit(pg.PageSize+1)), nil
+}
+
+// TotalPipeline returns the pipeline that fetches the given page and the total
+// result count in a single document using $facet, with the page in the
+// "results" field and the count in the "totalCount" field.
+func (pg Paginator[T]) TotalPipeline(page int64) (Pipeline, error) {
+	skip, err := pg.skip(page)
+	if err != nil {
+		return nil, err
+	}
+	return pg.Pipeline.Append(Facet(
+		Field("results", Pipeline{Skip(skip), Limit(pg.PageSize)}),
+		Field("totalCount", Pipeline{Count("count")}),
+	)), nil
+}
+
+// Page fetches the given page, starting at 1.
+func (pg Paginator[T]) Page(ctx context.Context, page int64) (Page[T], error) {
+	p, err := pg.PagePipeline(page)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	cursor, err := Aggregate(ctx, pg.Collection, p, pg.aggregateOptions()...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	var items []T
+	if err := cursor.All(ctx, &items); err != nil {
+		return Page[T]{}, err
+	}
+
+	res := Page[T]{
+		Items:      items,
+		Number:     page,
+		PageSize:   pg.PageSize,
+		TotalCount: -1,
+	}
+	if int64(len(items)) > pg.PageSize {
+		res.Items = items[:pg.PageSize]
+		res.HasMore = true
+	}
+	return res, nil
+}
+
+// PageWithTotal fetches the given page, starting at 1, and the total result
+// count. Counting requires the server to process every result of the base
+// pipeline, so prefer Page unless the total is needed.
+func (pg Paginator[T]) PageWithTotal(ctx context.Context, page int64) (Page[T], error) {
+	p, err := pg.TotalPipeline(page)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	cursor, err := Aggregate(ctx, pg.Collection, p, pg.aggregateOptions()...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return Page[T]{}, err
+		}
+		return Page[T]{}, errors.New("$facet returned no documents")
+	}
+	type facets struct {
+		Results    []T `bson:"results"`
+		TotalCount struct {
+			Count int64 `bson:"count"`
+		} `bson:"totalCount"`
+	}
+	f, err := DecodeFacets[facets](cursor.Current)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	skip, _ := pg.skip(page)
+	return Page[T]{
+		Items:      f.Results,
+		Number:     page,
+		PageSize:   pg.PageSize,
+		HasMore:    skip+int64(len(f.Results)) < f.TotalCount.Count,
+		TotalCount: f.TotalCount.Count,
+	}, nil
+}
@@ -0,0 +1,26 @@
+package agg
+
+// Pipe composes expressions left to right, passing expr to the first function,
+// its result to the second, and so on, returning the final result. It makes
+// nested expressions read in the order they're evaluated. For example,
+//
+//	agg.Pipe("$name", agg.Trim, agg.ToLower, func(s any) agg.Operator {
+//		return agg.Split(s, " ")
+//	})
+//
+// is equivalent to agg.Split(agg.ToLower(agg.Trim("$name")), " "). If there are
+// no functions, Pipe returns expr.
+func Pipe(expr any, fns ...func(any) Operator) any {
+	for _, fn := range fns {
+		expr = fn(expr)
+	}
+	return expr
+}
+
+// Then applies fn to the operator, allowing expressions to be composed with
+// method chaining:
+//
+//	agg.Trim("$name").Then(agg.ToLower)
+func (op Operator) Then(fn func(any) Operator) Operator {
+	return fn(op)
+}
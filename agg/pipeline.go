@@ -0,0 +1,251 @@
+package agg
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Pipeline is an aggregation pipeline. It can be passed directly to
+// Collection.Aggregate, Database.Aggregate, and Collection.Watch.
+//
+// Stage and Operator constructors never retain caller-provided slices, so a
+// base Pipeline can be cloned and extended concurrently as long as the base
+// itself isn't modified in place.
+type Pipeline []Stage
+
+// Clone returns a deep copy of the pipeline. Modifying the returned pipeline,
+// including any nested documents or arrays, does not modify p.
+func (p Pipeline) Clone() Pipeline {
+	if p == nil {
+		return nil
+	}
+	c := make(Pipeline, len(p))
+	for i := range p {
+		c[i] = CloneStage(p[i])
+	}
+	return c
+}
+
+// Append returns a new pipeline with stages appended to a copy of p. Unlike
+// the built-in append, the returned pipeline never shares a backing array
+// with p.
+func (p Pipeline) Append(stages ...Stage) Pipeline {
+	c := make(Pipeline, len(p), len(p)+len(stages))
+	copy(c, p)
+	return append(c, stages...)
+}
+
+// StageNames returns the name of each stage in p, like "$match". Stages that
+// aren't single-field documents have an empty name.
+func (p Pipeline) StageNames() []string {
+	names := make([]string, len(p))
+	for i := range p {
+		names[i] = stageName(p[i])
+	}
+	return names
+}
+
+// IndexOf returns the index of the first stage with the given name (e.g.
+// "$lookup"), or -1 if there is no such stage.
+func (p Pipeline) IndexOf(name string) int {
+	for i := range p {
+		if stageName(p[i]) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// InsertBefore returns a new pipeline with stages inserted before the stage
+// at index i. An i of len(p) appends the stages. Like the other editing
+// methods, it never modifies p and panics if i is out of range.
+//
+// For example, to add a tenant filter to a pipeline built elsewhere while
+// keeping a $geoNear stage first:
+//
+//	i := p.IndexOf("$geoNear") + 1
+//	p = p.InsertBefore(i, agg.Match(bson.D{{Key: "tenant", Value: tenant}}))
+func (p Pipeline) InsertBefore(i int, stages ...Stage) Pipeline {
+	if i < 0 || i > len(p) {
+		panic(fmt.Sprintf("agg: stage index %d out of range [0:%d]", i, len(p)))
+	}
+	c := make(Pipeline, 0, len(p)+len(stages))
+	c = append(c, p[:i]...)
+	c = append(c, stages...)
+	return append(c, p[i:]...)
+}
+
+// InsertAfter returns a new pipeline with stages inserted after the stage at
+// index i.
+func (p Pipeline) InsertAfter(i int, stages ...Stage) Pipeline {
+	if i < 0 || i >= len(p) {
+		panic(fmt.Sprintf("agg: stage index %d out of range [0:%d]", i, len(p)))
+	}
+	return p.InsertBefore(i+1, stages...)
+}
+
+// ReplaceStage returns a new pipeline with the stage at index i replaced by
+// stages. Passing no stages removes the stage.
+func (p Pipeline) ReplaceStage(i int, stages ...Stage) Pipeline {
+	if i < 0 || i >= len(p) {
+		panic(fmt.Sprintf("agg: stage index %d out of range [0:%d]", i, len(p)))
+	}
+	c := make(Pipeline, 0, len(p)-1+len(stages))
+	c = append(c, p[:i]...)
+	c = append(c, stages...)
+	return append(c, p[i+1:]...)
+}
+
+// RemoveStage returns a new pipeline without the stage at index i.
+func (p Pipeline) RemoveStage(i int) Pipeline {
+	return p.ReplaceStage(i)
+}
+
+// CloneStage returns a deep copy of the stage.
+func CloneStage(s Stage) Stage {
+	return cloneD(s)
+}
+
+// Clone returns a deep copy of the operator.
+func (op Operator) Clone() Operator {
+	return Operator(cloneD(bson.D(op)))
+}
+
+func cloneD(d bson.D) bson.D {
+	if d == nil {
+		return nil
+	}
+	c := make(bson.D, len(d))
+	for i := range d {
+		c[i] = bson.E{Key: d[i].Key, Value: cloneValue(d[i].Value)}
+	}
+	return c
+}
+
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case bson.D:
+		return cloneD(v)
+	case Operator:
+		return v.Clone()
+	case BoolExpr:
+		return BoolExpr{expr: cloneValue(v.expr)}
+	case bson.E:
+		return bson.E{Key: v.Key, Value: cloneValue(v.Value)}
+	case FieldExpr:
+		return FieldExpr{Key: v.Key, Value: cloneValue(v.Value)}
+	case SortBy:
+		return SortBy{Key: v.Key, Value: cloneValue(v.Value)}
+	case bson.A:
+		if v == nil {
+			return v
+		}
+		c := make(bson.A, len(v))
+		for i := range v {
+			c[i] = cloneValue(v[i])
+		}
+		return c
+	case []any:
+		if v == nil {
+			return v
+		}
+		c := make([]any, len(v))
+		for i := range v {
+			c[i] = cloneValue(v[i])
+		}
+		return c
+	case bson.M:
+		if v == nil {
+			return v
+		}
+		c := make(bson.M, len(v))
+		for k, e := range v {
+			c[k] = cloneValue(e)
+		}
+		return c
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = cloneValue(e)
+		}
+		return c
+	case Pipeline:
+		return v.Clone()
+	case []bson.D:
+		if v == nil {
+			return v
+		}
+		c := make([]bson.D, len(v))
+		for i := range v {
+			c[i] = cloneD(v[i])
+		}
+		return c
+	case []string:
+		if v == nil {
+			return v
+		}
+		return append([]string(nil), v...)
+	case bson.RawValue:
+		return bson.RawValue{Type: v.Type, Value: append([]byte(nil), v.Value...)}
+	case bson.Raw:
+		if v == nil {
+			return v
+		}
+		return append(bson.Raw(nil), v...)
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte(nil), v...)
+	default:
+		return cloneReflect(v)
+	}
+}
+
+// cloneReflect deep copies slices and maps of any type, like the
+// []query.BSONType built by IsType. Other values are returned as-is.
+func cloneReflect(v any) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			setCloned(c.Index(i), rv.Index(i))
+		}
+		return c.Interface()
+	case reflect.Map:
+		if rv.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			e := reflect.New(rv.Type().Elem()).Elem()
+			setCloned(e, iter.Value())
+			c.SetMapIndex(iter.Key(), e)
+		}
+		return c.Interface()
+	}
+	return v
+}
+
+// setCloned sets dst to a deep copy of src.
+func setCloned(dst, src reflect.Value) {
+	if src.Kind() == reflect.Interface && src.IsNil() {
+		return
+	}
+	c := reflect.ValueOf(cloneValue(src.Interface()))
+	if c.Type().AssignableTo(dst.Type()) {
+		dst.Set(c)
+		return
+	}
+	dst.Set(src)
+}
@@ -0,0 +1,31 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/matthewdale/mongo-go-exp/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPipelineClone(t *testing.T) {
+	base := Pipeline{
+		Match(bson.D{{Key: "$expr", Value: IsType("$a", query.TypeString, query.TypeInt)}}),
+		Project(Field("tags", []string{"a", "b"}), Field("weights", map[string][]float64{"w": {1, 2}})),
+	}
+	want := Pipeline{
+		Match(bson.D{{Key: "$expr", Value: IsType("$a", query.TypeString, query.TypeInt)}}),
+		Project(Field("tags", []string{"a", "b"}), Field("weights", map[string][]float64{"w": {1, 2}})),
+	}
+
+	c := base.Clone()
+	types := c[0][0].Value.(bson.D)[0].Value.(Operator)[0].Value.(bson.A)[1].([]query.BSONType)
+	types[0] = query.TypeDouble
+	proj := c[1][0].Value.(bson.D)
+	proj[0].Value.([]string)[0] = "x"
+	proj[1].Value.(map[string][]float64)["w"][0] = 3
+
+	if !reflect.DeepEqual(base, want) {
+		t.Errorf("modifying the clone modified the base pipeline: got %v, want %v", base, want)
+	}
+}
@@ -0,0 +1,162 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PipelineRegistry holds named, versioned pipeline definitions, so an
+// application's pipelines can be reviewed in one place and run by name. The
+// zero value is an empty registry ready to use, and it's safe for concurrent
+// use.
+//
+// For example, registering a definition loaded from a config file and running
+// its latest version:
+//
+//	var reg agg.PipelineRegistry
+//	if err := reg.RegisterYAML(1, data); err != nil {
+//		return err
+//	}
+//	p, err := reg.Bind("topCustomers", map[string]any{"limit": 10})
+type PipelineRegistry struct {
+	mu   sync.RWMutex
+	defs map[string]map[int]Definition
+}
+
+// Register adds version of the definition. It returns an error if the
+// definition has no name, the version is less than 1, or the version is
+// already registered.
+func (r *PipelineRegistry) Register(version int, d Definition) error {
+	if d.Name == "" {
+		return errors.New("pipeline definition must have a name")
+	}
+	if version < 1 {
+		return fmt.Errorf("pipeline %q version must be at least 1, got %d", d.Name, version)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.defs == nil {
+		r.defs = make(map[string]map[int]Definition)
+	}
+	versions := r.defs[d.Name]
+	if versions == nil {
+		versions = make(map[int]Definition)
+		r.defs[d.Name] = versions
+	}
+	if _, ok := versions[version]; ok {
+		return fmt.Errorf("pipeline %q version %d is already registered", d.Name, version)
+	}
+	versions[version] = d.clone()
+	return nil
+}
+
+// RegisterJSON parses a JSON pipeline definition (see Definition) and
+// registers it as version.
+func (r *PipelineRegistry) RegisterJSON(version int, data []byte) error {
+	d, err := ParseDefinitionJSON(data)
+	if err != nil {
+		return err
+	}
+	return r.Register(version, d)
+}
+
+// RegisterYAML parses a YAML pipeline definition (see Definition) and
+// registers it as version.
+func (r *PipelineRegistry) RegisterYAML(version int, data []byte) error {
+	d, err := ParseDefinitionYAML(data)
+	if err != nil {
+		return err
+	}
+	return r.Register(version, d)
+}
+
+// Get returns the given version of the named definition.
+func (r *PipelineRegistry) Get(name string, version int) (Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.defs[name][version]
+	return d.clone(), ok
+}
+
+// Latest returns the highest version of the named definition and its version
+// number.
+func (r *PipelineRegistry) Latest(name string) (Definition, int, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	latest := 0
+	for v := range r.defs[name] {
+		if v > latest {
+			latest = v
+		}
+	}
+	if latest == 0 {
+		return Definition{}, 0, false
+	}
+	return r.defs[name][latest].clone(), latest, true
+}
+
+// Names returns the sorted names of the registered definitions.
+func (r *PipelineRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.defs))
+	for name := range r.defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions returns the sorted registered versions of the named definition.
+func (r *PipelineRegistry) Versions(name string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := make([]int, 0, len(r.defs[name]))
+	for v := range r.defs[name] {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Bind binds args to the latest version of the named definition (see
+// Definition.Bind). The returned pipeline is tagged with a comment like
+// "topCustomers@v2" (see Pipeline.WithComment), so the server's slow query logs and
+// profiler, and any metrics keyed by comment, identify the pipeline.
+func (r *PipelineRegistry) Bind(name string, args map[string]any) (Pipeline, error) {
+	d, version, ok := r.Latest(name)
+	if !ok {
+		return nil, fmt.Errorf("no pipeline named %q", name)
+	}
+	return bindVersion(d, version, args)
+}
+
+// BindVersion is like Bind, but uses the given version of the definition.
+func (r *PipelineRegistry) BindVersion(name string, version int, args map[string]any) (Pipeline, error) {
+	d, ok := r.Get(name, version)
+	if !ok {
+		return nil, fmt.Errorf("no pipeline named %q with version %d", name, version)
+	}
+	return bindVersion(d, version, args)
+}
+
+func bindVersion(d Definition, version int, args map[string]any) (Pipeline, error) {
+	p, err := d.Bind(args)
+	if err != nil {
+		return nil, err
+	}
+	return p.WithComment(fmt.Sprintf("%s@v%d", d.Name, version)), nil
+}
+
+// clone returns a copy of d that doesn't share its Params or Pipeline with d.
+func (d Definition) clone() Definition {
+	d.Params = append([]ParamSpec(nil), d.Params...)
+	for i := range d.Params {
+		d.Params[i].Default = cloneValue(d.Params[i].Default)
+	}
+	d.Pipeline = d.Pipeline.Clone()
+	return d
+}
@@ -0,0 +1,62 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPipelineRegistryBindFirstStage(t *testing.T) {
+	var reg PipelineRegistry
+	err := reg.Register(2, Definition{
+		Name:   "nearby",
+		Params: []ParamSpec{{Name: "limit", Default: int64(10)}},
+		Pipeline: Pipeline{
+			GeoNear(bson.D{}, "dist"),
+			Stage{{Key: "$limit", Value: Param("limit")}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+
+	p, err := reg.Bind("nearby", nil)
+	if err != nil {
+		t.Fatalf("error binding: %v", err)
+	}
+	want := []string{"$geoNear", "$match", "$limit"}
+	if got := p.StageNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got stages %v, want %v", got, want)
+	}
+	if c, ok := p.Comment(); !ok || c != "nearby@v2" {
+		t.Errorf(`got comment %q, %v, want "nearby@v2", true`, c, ok)
+	}
+}
+
+func TestPipelineRegistryGetReturnsCopy(t *testing.T) {
+	var reg PipelineRegistry
+	err := reg.Register(1, Definition{
+		Name:     "top",
+		Params:   []ParamSpec{{Name: "n", Default: int64(5)}},
+		Pipeline: Pipeline{Stage{{Key: "$limit", Value: Param("n")}}},
+	})
+	if err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+
+	d, _ := reg.Get("top", 1)
+	d.Params[0].Default = int64(100)
+	d.Pipeline[0][0].Value = int64(1)
+	d, _, _ = reg.Latest("top")
+	d.Pipeline[0][0].Key = "$skip"
+
+	p, err := reg.Bind("top", nil)
+	if err != nil {
+		t.Fatalf("error binding: %v", err)
+	}
+	want := Stage{{Key: "$limit", Value: int64(5)}}
+	if got := p[len(p)-1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got stage %v, want %v", got, want)
+	}
+}
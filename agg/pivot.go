@@ -0,0 +1,41 @@
+package agg
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// PivotToObject returns a $group accumulator that collects the documents in
+// each group into a single document with a field named by keyExpr (converted
+// to a string) set to valueExpr. If multiple documents have the same key, the
+// last value wins. For example, grouping sales by region with
+//
+//	agg.Field("byMonth", agg.PivotToObject("$month", "$total"))
+//
+// produces documents like {_id: "west", byMonth: {"1": 120, "2": 95}}.
+func PivotToObject(keyExpr, valueExpr any) Operator {
+	return Operator{{
+		Key: "$mergeObjects",
+		Value: ArrayToObject(bson.A{bson.A{
+			bson.D{
+				{Key: "k", Value: ToString(keyExpr)},
+				{Key: "v", Value: valueExpr},
+			},
+		}}),
+	}}
+}
+
+// Pivot returns a pipeline that groups documents by groupKey and pivots each
+// group's documents into a document in the field as, using the $push of k/v
+// pairs followed by $arrayToObject. It's equivalent to grouping with
+// PivotToObject, but builds the object once per group instead of once per
+// document.
+func Pivot(groupKey, keyExpr, valueExpr any, as string) Pipeline {
+	return Pipeline{
+		Group(groupKey, Field(as, Operator{{
+			Key: "$push",
+			Value: bson.D{
+				{Key: "k", Value: ToString(keyExpr)},
+				{Key: "v", Value: valueExpr},
+			},
+		}})),
+		AddFields(Field(as, ArrayToObject("$"+as))),
+	}
+}
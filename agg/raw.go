@@ -0,0 +1,44 @@
+package agg
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// RawStage returns a stage from an already-marshaled stage document, like
+// {"$match": {...}}. Only the stage name is decoded; the stage body is copied
+// from doc and marshaled as-is. A bson.RawValue can also be used directly
+// wherever an expression is expected.
+func RawStage(doc bson.Raw) (Stage, error) {
+	elems, err := doc.Elements()
+	if err != nil {
+		return nil, fmt.Errorf("invalid stage document: %w", err)
+	}
+	if len(elems) != 1 {
+		return nil, fmt.Errorf("stage document must have exactly 1 field, got %d", len(elems))
+	}
+	return Stage{{Key: elems[0].Key(), Value: cloneValue(elems[0].Value())}}, nil
+}
+
+// RawPipeline returns a pipeline from an already-marshaled BSON array of stage
+// documents, like a pipeline fragment stored in a database.
+func RawPipeline(arr bson.Raw) (Pipeline, error) {
+	values, err := arr.Values()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline array: %w", err)
+	}
+
+	p := make(Pipeline, len(values))
+	for i, v := range values {
+		if v.Type != bsontype.EmbeddedDocument {
+			return nil, fmt.Errorf("pipeline stage %d must be a document, got BSON type %s", i, v.Type)
+		}
+		p[i], err = RawStage(v.Document())
+		if err != nil {
+			return nil, fmt.Errorf("invalid pipeline stage %d: %w", i, err)
+		}
+	}
+	return p, nil
+}
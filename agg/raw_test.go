@@ -0,0 +1,49 @@
+package agg
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRawPipelineCopiesInput(t *testing.T) {
+	stages := bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "x", Value: int32(1)}}}}}
+	_, arr, err := bson.MarshalValue(stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := RawPipeline(arr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, err := bson.MarshalExtJSON(bson.D{{Key: "p", Value: p}}, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range arr {
+		arr[i] = 0
+	}
+	got, err := bson.MarshalExtJSON(bson.D{{Key: "p", Value: p}}, true, false)
+	if err != nil {
+		t.Fatalf("error marshaling pipeline after modifying the input: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("modifying the input modified the pipeline: got %s, want %s", got, want)
+	}
+}
+
+func TestCloneRawValue(t *testing.T) {
+	_, b, err := bson.MarshalValue("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := Pipeline{Match(bson.D{{Key: "s", Value: bson.RawValue{Type: bson.TypeString, Value: b}}})}
+	c := base.Clone()
+	raw := c[0][0].Value.(bson.D)[0].Value.(bson.RawValue)
+	raw.Value[len(raw.Value)-2] = 'x'
+
+	if got := base[0][0].Value.(bson.D)[0].Value.(bson.RawValue).StringValue(); got != "abc" {
+		t.Errorf("modifying the clone modified the base pipeline: got %q, want %q", got, "abc")
+	}
+}
@@ -0,0 +1,121 @@
+package agg
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RefreshJob is a pipeline, usually ending in a $merge or $out stage, that a
+// Refresher runs periodically.
+type RefreshJob struct {
+	// Name identifies the job in RefreshMetrics.
+	Name string
+
+	// Collection is the collection the pipeline runs on.
+	Collection *mongo.Collection
+
+	// Pipeline returns the pipeline to run. lastSuccess is the start time of
+	// the last successful run, or the zero time if the job hasn't succeeded
+	// yet, and can be used to only process recently changed documents (see
+	// MaterializedView.Pipeline).
+	Pipeline func(lastSuccess time.Time) Pipeline
+
+	// Collation is the collation used by the pipeline, if not nil.
+	Collation *options.Collation
+
+	// Exec configures the aggregate command.
+	Exec []ExecOption
+}
+
+// RefreshMetrics describe a single run of a RefreshJob.
+type RefreshMetrics struct {
+	Job      string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Refresher periodically runs RefreshJobs, like the refresh pipelines of
+// on-demand materialized views.
+type Refresher struct {
+	Jobs []RefreshJob
+
+	// Interval is the time between the start of consecutive runs. If a run
+	// takes longer than Interval, the next run starts as soon as it finishes.
+	// It must be positive.
+	Interval time.Duration
+
+	// Jitter is the maximum random duration added to each Interval, so
+	// multiple processes running the same Refresher don't run in lockstep.
+	Jitter time.Duration
+
+	// OnRun, if set, is called after every job run.
+	OnRun func(RefreshMetrics)
+
+	// OnError, if set, is called after every failed job run.
+	OnError func(job string, err error)
+}
+
+// Run runs all jobs immediately and then once every Interval (plus jitter)
+// until ctx is canceled. Jobs run sequentially in the order they're listed. A
+// failed job doesn't stop the other jobs or later runs. Run returns the
+// context's error, or an error if Interval isn't positive.
+func (r *Refresher) Run(ctx context.Context) error {
+	if r.Interval <= 0 {
+		return fmt.Errorf("refresh interval must be positive, got %v", r.Interval)
+	}
+
+	lastSuccess := make([]time.Time, len(r.Jobs))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		for i, job := range r.Jobs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			m := r.runJob(ctx, job, lastSuccess[i])
+			if m.Err == nil {
+				lastSuccess[i] = m.Start
+			}
+		}
+
+		next := r.Interval
+		if r.Jitter > 0 {
+			next += time.Duration(rand.Int63n(int64(r.Jitter)))
+		}
+		// Subtract the time the jobs took so runs start every Interval.
+		timer.Reset(next - time.Since(start))
+	}
+}
+
+func (r *Refresher) runJob(ctx context.Context, job RefreshJob, lastSuccess time.Time) RefreshMetrics {
+	m := RefreshMetrics{Job: job.Name, Start: time.Now()}
+
+	cur, err := Aggregate(ctx, job.Collection, job.Pipeline(lastSuccess), execOptions(job.Collation, job.Exec)...)
+	if err == nil {
+		err = cur.Close(ctx)
+	}
+	m.Duration = time.Since(m.Start)
+	m.Err = err
+
+	if r.OnRun != nil {
+		r.OnRun(m)
+	}
+	if err != nil && r.OnError != nil {
+		r.OnError(job.Name, err)
+	}
+	return m
+}
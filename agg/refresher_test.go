@@ -0,0 +1,16 @@
+package agg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRefresherRunRequiresInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		r := Refresher{Interval: interval}
+		if err := r.Run(context.Background()); err == nil {
+			t.Errorf("expected an error for interval %v", interval)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package agg
+
+import "sort"
+
+type OperatorCategory string
+
+const (
+	CategoryArithmetic  OperatorCategory = "arithmetic"
+	CategoryArray       OperatorCategory = "array"
+	CategoryBoolean     OperatorCategory = "boolean"
+	CategoryComparison  OperatorCategory = "comparison"
+	CategoryConditional OperatorCategory = "conditional"
+	CategoryDate        OperatorCategory = "date"
+	CategoryLiteral     OperatorCategory = "literal"
+	CategoryMisc        OperatorCategory = "miscellaneous"
+	CategoryObject      OperatorCategory = "object"
+	CategorySet         OperatorCategory = "set"
+	CategoryString      OperatorCategory = "string"
+	CategoryText        OperatorCategory = "text"
+	CategoryTrig        OperatorCategory = "trigonometry"
+	CategoryType        OperatorCategory = "type"
+	CategoryVariable    OperatorCategory = "variable"
+	CategoryAccumulator OperatorCategory = "accumulator"
+	CategoryWindow      OperatorCategory = "window"
+)
+
+// UnboundedArgs is the MaxArgs value for operators that take any number of
+// arguments.
+const UnboundedArgs = -1
+
+// OperatorInfo describes an aggregation expression operator.
+type OperatorInfo struct {
+	Name     string
+	Category OperatorCategory
+
+	// MinArgs and MaxArgs are the number of arguments the operator takes in
+	// array form. Operators that take a single expression or a document of
+	// named arguments (NamedArgs) take 1 argument. MaxArgs is UnboundedArgs for
+	// operators that take any number of arguments.
+	MinArgs   int
+	MaxArgs   int
+	NamedArgs bool
+
+	// Accumulator is true if the operator can be used as a $group accumulator.
+	Accumulator bool
+
+	// Window is true if the operator can be used as a $setWindowFields window
+	// operator.
+	Window bool
+
+	// MinServerVersion is the first MongoDB server version that supports the
+	// operator in any context. Some operators are supported in more contexts
+	// in later versions (e.g. $first as an array expression in 4.4).
+	MinServerVersion string
+}
+
+// StageInfo describes an aggregation pipeline stage.
+type StageInfo struct {
+	Name             string
+	MinServerVersion string
+
+	// First is true if the stage must be the first stage in the pipeline.
+	First bool
+	// Last is true if the stage must be the last stage in the pipeline.
+	Last bool
+}
+
+func unary(name string, category OperatorCategory, version string) OperatorInfo {
+	return OperatorInfo{Name: name, Category: category, MinArgs: 1, MaxArgs: 1, MinServerVersion: version}
+}
+
+func binary(name string, category OperatorCategory, version string) OperatorInfo {
+	return OperatorInfo{Name: name, Category: category, MinArgs: 2, MaxArgs: 2, MinServerVersion: version}
+}
+
+func variadic(name string, category OperatorCategory, version string, min int) OperatorInfo {
+	return OperatorInfo{Name: name, Category: category, MinArgs: min, MaxArgs: UnboundedArgs, MinServerVersion: version}
+}
+
+func named(name string, category OperatorCategory, version string) OperatorInfo {
+	return OperatorInfo{Name: name, Category: category, MinArgs: 1, MaxArgs: 1, NamedArgs: true, MinServerVersion: version}
+}
+
+// accumulator marks the operator as usable as a $group accumulator.
+func accumulator(info OperatorInfo) OperatorInfo {
+	info.Accumulator = true
+	return info
+}
+
+// window marks the operator as usable as a $setWindowFields operator.
+func window(info OperatorInfo) OperatorInfo {
+	info.Window = true
+	return info
+}
+
+var operatorInfos = []OperatorInfo{
+	// Arithmetic
+	unary("$abs", CategoryArithmetic, "3.2"),
+	variadic("$add", CategoryArithmetic, "2.2", 0),
+	unary("$ceil", CategoryArithmetic, "3.2"),
+	binary("$divide", CategoryArithmetic, "2.2"),
+	unary("$exp", CategoryArithmetic, "3.2"),
+	unary("$floor", CategoryArithmetic, "3.2"),
+	unary("$ln", CategoryArithmetic, "3.2"),
+	binary("$log", CategoryArithmetic, "3.2"),
+	unary("$log10", CategoryArithmetic, "3.2"),
+	binary("$mod", CategoryArithmetic, "2.2"),
+	variadic("$multiply", CategoryArithmetic, "2.2", 0),
+	binary("$pow", CategoryArithmetic, "3.2"),
+	{Name: "$round", Category: CategoryArithmetic, MinArgs: 1, MaxArgs: 2, MinServerVersion: "4.2"},
+	unary("$sigmoid", CategoryArithmetic, "8.2"),
+	unary("$sqrt", CategoryArithmetic, "3.2"),
+	binary("$subtract", CategoryArithmetic, "2.2"),
+	{Name: "$trunc", Category: CategoryArithmetic, MinArgs: 1, MaxArgs: 2, MinServerVersion: "3.2"},
+
+	// Array
+	binary("$arrayElemAt", CategoryArray, "3.2"),
+	unary("$arrayToObject", CategoryArray, "3.4.4"),
+	variadic("$concatArrays", CategoryArray, "3.2", 0),
+	named("$filter", CategoryArray, "3.2"),
+	window(accumulator(unary("$first", CategoryArray, "2.2"))),
+	window(accumulator(named("$firstN", CategoryArray, "5.2"))),
+	binary("$in", CategoryArray, "3.4"),
+	{Name: "$indexOfArray", Category: CategoryArray, MinArgs: 2, MaxArgs: 4, MinServerVersion: "3.4"},
+	unary("$isArray", CategoryArray, "3.2"),
+	window(accumulator(unary("$last", CategoryArray, "2.2"))),
+	window(accumulator(named("$lastN", CategoryArray, "5.2"))),
+	named("$map", CategoryArray, "2.6"),
+	window(accumulator(named("$maxN", CategoryArray, "5.2"))),
+	window(accumulator(named("$minN", CategoryArray, "5.2"))),
+	unary("$objectToArray", CategoryArray, "3.4.4"),
+	{Name: "$range", Category: CategoryArray, MinArgs: 2, MaxArgs: 3, MinServerVersion: "3.4"},
+	named("$reduce", CategoryArray, "3.4"),
+	unary("$reverseArray", CategoryArray, "3.4"),
+	unary("$size", CategoryArray, "2.6"),
+	{Name: "$slice", Category: CategoryArray, MinArgs: 2, MaxArgs: 3, MinServerVersion: "3.2"},
+	named("$sortArray", CategoryArray, "5.2"),
+	named("$zip", CategoryArray, "3.4"),
+
+	// Boolean
+	variadic("$and", CategoryBoolean, "2.2", 0),
+	unary("$not", CategoryBoolean, "2.2"),
+	variadic("$or", CategoryBoolean, "2.2", 0),
+
+	// Comparison
+	binary("$cmp", CategoryComparison, "2.2"),
+	binary("$eq", CategoryComparison, "2.2"),
+	binary("$gt", CategoryComparison, "2.2"),
+	binary("$gte", CategoryComparison, "2.2"),
+	binary("$lt", CategoryComparison, "2.2"),
+	binary("$lte", CategoryComparison, "2.2"),
+	binary("$ne", CategoryComparison, "2.2"),
+
+	// Conditional
+	{Name: "$cond", Category: CategoryConditional, MinArgs: 3, MaxArgs: 3, NamedArgs: true, MinServerVersion: "2.6"},
+	variadic("$ifNull", CategoryConditional, "2.2", 2),
+	named("$switch", CategoryConditional, "3.4"),
+
+	// Date
+	named("$dateAdd", CategoryDate, "5.0"),
+	named("$dateDiff", CategoryDate, "5.0"),
+	named("$dateFromParts", CategoryDate, "3.6"),
+	named("$dateFromString", CategoryDate, "3.6"),
+	named("$dateSubtract", CategoryDate, "5.0"),
+	named("$dateToParts", CategoryDate, "3.6"),
+	named("$dateToString", CategoryDate, "3.0"),
+	named("$dateTrunc", CategoryDate, "5.0"),
+	unary("$dayOfMonth", CategoryDate, "2.2"),
+	unary("$dayOfWeek", CategoryDate, "2.2"),
+	unary("$dayOfYear", CategoryDate, "2.2"),
+	unary("$hour", CategoryDate, "2.2"),
+	unary("$isoDayOfWeek", CategoryDate, "3.4"),
+	unary("$isoWeek", CategoryDate, "3.4"),
+	unary("$isoWeekYear", CategoryDate, "3.4"),
+	unary("$millisecond", CategoryDate, "2.4"),
+	unary("$minute", CategoryDate, "2.2"),
+	unary("$month", CategoryDate, "2.2"),
+	unary("$second", CategoryDate, "2.2"),
+	unary("$week", CategoryDate, "2.2"),
+	unary("$year", CategoryDate, "2.2"),
+
+	// Literal
+	unary("$literal", CategoryLiteral, "2.6"),
+
+	// Miscellaneous
+	named("$getField", CategoryMisc, "5.0"),
+	{Name: "$rand", Category: CategoryMisc, MinArgs: 0, MaxArgs: 0, MinServerVersion: "4.4.2"},
+	unary("$sampleRate", CategoryMisc, "4.4.2"),
+	unary("$toHashedIndexKey", CategoryMisc, "7.0"),
+
+	// Object
+	accumulator(variadic("$mergeObjects", CategoryObject, "3.6", 1)),
+	named("$setField", CategoryObject, "5.0"),
+	named("$unsetField", CategoryObject, "5.0"),
+
+	// Set
+	unary("$allElementsTrue", CategorySet, "2.6"),
+	unary("$anyElementTrue", CategorySet, "2.6"),
+	binary("$setDifference", CategorySet, "2.6"),
+	variadic("$setEquals", CategorySet, "2.6", 2),
+	variadic("$setIntersection", CategorySet, "2.6", 0),
+	binary("$setIsSubset", CategorySet, "2.6"),
+	variadic("$setUnion", CategorySet, "2.6", 0),
+
+	// String
+	variadic("$concat", CategoryString, "2.4", 0),
+	{Name: "$indexOfBytes", Category: CategoryString, MinArgs: 2, MaxArgs: 4, MinServerVersion: "3.4"},
+	{Name: "$indexOfCP", Category: CategoryString, MinArgs: 2, MaxArgs: 4, MinServerVersion: "3.4"},
+	named("$ltrim", CategoryString, "4.0"),
+	named("$regexFind", CategoryString, "4.2"),
+	named("$regexFindAll", CategoryString, "4.2"),
+	named("$regexMatch", CategoryString, "4.2"),
+	named("$replaceAll", CategoryString, "4.4"),
+	named("$replaceOne", CategoryString, "4.4"),
+	named("$rtrim", CategoryString, "4.0"),
+	binary("$split", CategoryString, "3.4"),
+	unary("$strLenBytes", CategoryString, "3.4"),
+	unary("$strLenCP", CategoryString, "3.4"),
+	binary("$strcasecmp", CategoryString, "2.2"),
+	{Name: "$substrBytes", Category: CategoryString, MinArgs: 3, MaxArgs: 3, MinServerVersion: "3.4"},
+	{Name: "$substrCP", Category: CategoryString, MinArgs: 3, MaxArgs: 3, MinServerVersion: "3.4"},
+	unary("$toLower", CategoryString, "2.2"),
+	unary("$toUpper", CategoryString, "2.2"),
+	named("$trim", CategoryString, "4.0"),
+
+	// Text
+	unary("$meta", CategoryText, "2.6"),
+
+	// Trigonometry
+	unary("$acos", CategoryTrig, "4.2"),
+	unary("$asin", CategoryTrig, "4.2"),
+	unary("$atan", CategoryTrig, "4.2"),
+	binary("$atan2", CategoryTrig, "4.2"),
+	unary("$cos", CategoryTrig, "4.2"),
+	unary("$degreesToRadians", CategoryTrig, "4.2"),
+	unary("$radiansToDegrees", CategoryTrig, "4.2"),
+	unary("$sin", CategoryTrig, "4.2"),
+	unary("$tan", CategoryTrig, "4.2"),
+
+	// Type
+	named("$convert", CategoryType, "4.0"),
+	unary("$isNumber", CategoryType, "4.4"),
+	unary("$toBool", CategoryType, "4.0"),
+	unary("$toDate", CategoryType, "4.0"),
+	unary("$toDecimal", CategoryType, "4.0"),
+	unary("$toDouble", CategoryType, "4.0"),
+	unary("$toInt", CategoryType, "4.0"),
+	unary("$toLong", CategoryType, "4.0"),
+	unary("$toObjectId", CategoryType, "4.0"),
+	unary("$toString", CategoryType, "4.0"),
+	unary("$toUUID", CategoryType, "8.0"),
+	unary("$type", CategoryType, "3.4"),
+
+	// Variable
+	named("$let", CategoryVariable, "2.6"),
+
+	// Accumulators that are also expression operators.
+	window(accumulator(variadic("$avg", CategoryArithmetic, "2.2", 1))),
+	window(accumulator(variadic("$max", CategoryArithmetic, "2.2", 1))),
+	window(accumulator(variadic("$min", CategoryArithmetic, "2.2", 1))),
+	window(accumulator(variadic("$stdDevPop", CategoryArithmetic, "3.2", 1))),
+	window(accumulator(variadic("$stdDevSamp", CategoryArithmetic, "3.2", 1))),
+	window(accumulator(variadic("$sum", CategoryArithmetic, "2.2", 1))),
+	window(accumulator(named("$median", CategoryArithmetic, "7.0"))),
+	window(accumulator(named("$percentile", CategoryArithmetic, "7.0"))),
+
+	// Accumulators
+	accumulator(named("$accumulator", CategoryAccumulator, "4.4")),
+	window(accumulator(unary("$addToSet", CategoryAccumulator, "2.2"))),
+	window(accumulator(named("$bottom", CategoryAccumulator, "5.2"))),
+	window(accumulator(named("$bottomN", CategoryAccumulator, "5.2"))),
+	window(accumulator(named("$count", CategoryAccumulator, "5.0"))),
+	window(accumulator(unary("$push", CategoryAccumulator, "2.2"))),
+	window(accumulator(named("$top", CategoryAccumulator, "5.2"))),
+	window(accumulator(named("$topN", CategoryAccumulator, "5.2"))),
+
+	// Window operators
+	window(binary("$covariancePop", CategoryWindow, "5.0")),
+	window(binary("$covarianceSamp", CategoryWindow, "5.0")),
+	window(named("$denseRank", CategoryWindow, "5.0")),
+	window(named("$derivative", CategoryWindow, "5.0")),
+	window(named("$documentNumber", CategoryWindow, "5.0")),
+	window(named("$expMovingAvg", CategoryWindow, "5.0")),
+	window(named("$integral", CategoryWindow, "5.0")),
+	window(unary("$linearFill", CategoryWindow, "5.3")),
+	window(unary("$locf", CategoryWindow, "5.2")),
+	window(named("$minMaxScaler", CategoryWindow, "8.2")),
+	window(named("$rank", CategoryWindow, "5.0")),
+	window(named("$shift", CategoryWindow, "5.0")),
+}
+
+var stageInfos = []StageInfo{
+	{Name: "$addFields", MinServerVersion: "3.4"},
+	{Name: "$bucket", MinServerVersion: "3.4"},
+	{Name: "$bucketAuto", MinServerVersion: "3.4"},
+	{Name: "$changeStream", MinServerVersion: "3.6", First: true},
+	{Name: "$changeStreamSplitLargeEvent", MinServerVersion: "7.0", Last: true},
+	{Name: "$collStats", MinServerVersion: "3.4", First: true},
+	{Name: "$count", MinServerVersion: "3.4"},
+	{Name: "$currentOp", MinServerVersion: "3.6", First: true},
+	{Name: "$densify", MinServerVersion: "5.1"},
+	{Name: "$documents", MinServerVersion: "5.1", First: true},
+	{Name: "$facet", MinServerVersion: "3.4"},
+	{Name: "$fill", MinServerVersion: "5.3"},
+	{Name: "$geoNear", MinServerVersion: "2.4", First: true},
+	{Name: "$graphLookup", MinServerVersion: "3.4"},
+	{Name: "$group", MinServerVersion: "2.2"},
+	{Name: "$indexStats", MinServerVersion: "3.2", First: true},
+	{Name: "$limit", MinServerVersion: "2.2"},
+	{Name: "$listLocalSessions", MinServerVersion: "3.6", First: true},
+	{Name: "$listSampledQueries", MinServerVersion: "7.0", First: true},
+	{Name: "$listSearchIndexes", MinServerVersion: "6.0.7", First: true},
+	{Name: "$listSessions", MinServerVersion: "3.6", First: true},
+	{Name: "$lookup", MinServerVersion: "3.2"},
+	{Name: "$match", MinServerVersion: "2.2"},
+	{Name: "$merge", MinServerVersion: "4.2", Last: true},
+	{Name: "$out", MinServerVersion: "2.6", Last: true},
+	{Name: "$planCacheStats", MinServerVersion: "4.2", First: true},
+	{Name: "$project", MinServerVersion: "2.2"},
+	{Name: "$querySettings", MinServerVersion: "8.0", First: true},
+	{Name: "$redact", MinServerVersion: "2.6"},
+	{Name: "$replaceRoot", MinServerVersion: "3.4"},
+	{Name: "$replaceWith", MinServerVersion: "4.2"},
+	{Name: "$sample", MinServerVersion: "3.2"},
+	{Name: "$search", MinServerVersion: "4.2", First: true},
+	{Name: "$searchMeta", MinServerVersion: "4.4.9", First: true},
+	{Name: "$set", MinServerVersion: "4.2"},
+	{Name: "$setWindowFields", MinServerVersion: "5.0"},
+	{Name: "$shardedDataDistribution", MinServerVersion: "6.0.3", First: true},
+	{Name: "$skip", MinServerVersion: "2.2"},
+	{Name: "$sort", MinServerVersion: "2.2"},
+	{Name: "$sortByCount", MinServerVersion: "3.4"},
+	{Name: "$unionWith", MinServerVersion: "4.4"},
+	{Name: "$unset", MinServerVersion: "4.2"},
+	{Name: "$unwind", MinServerVersion: "2.2"},
+	{Name: "$vectorSearch", MinServerVersion: "7.0.2", First: true},
+}
+
+var (
+	operatorsByName = indexByName(operatorInfos, func(info OperatorInfo) string { return info.Name })
+	stagesByName    = indexByName(stageInfos, func(info StageInfo) string { return info.Name })
+)
+
+func indexByName[T any](infos []T, name func(T) string) map[string]T {
+	m := make(map[string]T, len(infos))
+	for _, info := range infos {
+		m[name(info)] = info
+	}
+	return m
+}
+
+// Operators returns metadata for all known expression operators, including
+// accumulators and window operators, sorted by name.
+func Operators() []OperatorInfo {
+	ops := append([]OperatorInfo(nil), operatorInfos...)
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Name < ops[j].Name })
+	return ops
+}
+
+// Stages returns metadata for all known pipeline stages, sorted by name.
+func Stages() []StageInfo {
+	return append([]StageInfo(nil), stageInfos...)
+}
+
+// LookupOperator returns metadata for the named operator, like "$add".
+func LookupOperator(name string) (OperatorInfo, bool) {
+	info, ok := operatorsByName[name]
+	return info, ok
+}
+
+// LookupStage returns metadata for the named stage, like "$match".
+func LookupStage(name string) (StageInfo, bool) {
+	info, ok := stagesByName[name]
+	return info, ok
+}
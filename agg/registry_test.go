@@ -0,0 +1,41 @@
+package agg
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLookupOperatorMergeObjects(t *testing.T) {
+	info, ok := LookupOperator("$mergeObjects")
+	if !ok {
+		t.Fatal("$mergeObjects not found")
+	}
+	if !info.Accumulator || info.Window {
+		t.Errorf("got Accumulator %v, Window %v, want true, false", info.Accumulator, info.Window)
+	}
+}
+
+func TestValidateStagesAdminStages(t *testing.T) {
+	for _, name := range []string{
+		"$currentOp",
+		"$listLocalSessions",
+		"$listSampledQueries",
+		"$listSessions",
+		"$planCacheStats",
+		"$shardedDataDistribution",
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := Pipeline{
+				Stage{{Key: name, Value: bson.D{}}},
+				Match(bson.D{{Key: "x", Value: 1}}),
+			}
+			if err := validateStages(p); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err := validateStages(Pipeline{p[1], p[0]}); err == nil {
+				t.Errorf("got no error for %s after $match, want an error", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrUnsafeInput is returned (wrapped) by the validation helpers when a field
+// name or value could be interpreted as an operator or field path.
+var ErrUnsafeInput = errors.New("unsafe input")
+
+// ValidateFieldName returns an error if name can't safely be used as a single
+// field name. Names that are empty, start with '$', or contain '.' or a null
+// byte are rejected, because they can be interpreted as operators or paths to
+// other fields.
+func ValidateFieldName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: field name must not be empty", ErrUnsafeInput)
+	case strings.HasPrefix(name, "$"):
+		return fmt.Errorf("%w: field name %q must not start with '$'", ErrUnsafeInput, name)
+	case strings.Contains(name, "."):
+		return fmt.Errorf("%w: field name %q must not contain '.'", ErrUnsafeInput, name)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("%w: field name %q must not contain null bytes", ErrUnsafeInput, name)
+	}
+	return nil
+}
+
+// ValidateFieldPath returns an error if path can't safely be used as a dotted
+// field path, i.e. if any of its '.'-separated parts is not a valid field name.
+func ValidateFieldPath(path string) error {
+	for _, part := range strings.Split(path, ".") {
+		if err := ValidateFieldName(part); err != nil {
+			return fmt.Errorf("invalid field path %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// ValidateValue returns an error if v is or contains a document with a field
+// name starting with '$', which could be interpreted as a query or expression
+// operator (e.g. {"$ne": null} in a $match filter).
+func ValidateValue(v any) error {
+	return validateValue(reflect.ValueOf(v))
+}
+
+func validateValue(rv reflect.Value) error {
+	if !rv.IsValid() {
+		return nil
+	}
+
+	switch v := rv.Interface().(type) {
+	case bson.D:
+		for _, e := range v {
+			if err := validateKey(e.Key); err != nil {
+				return err
+			}
+			if err := ValidateValue(e.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case bson.E:
+		if err := validateKey(v.Key); err != nil {
+			return err
+		}
+		return ValidateValue(v.Value)
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return validateValue(rv.Elem())
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := validateKey(iter.Key().String()); err != nil {
+				return err
+			}
+			if err := validateValue(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := validateValue(rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateKey(key string) error {
+	if strings.HasPrefix(key, "$") {
+		return fmt.Errorf("%w: document field name %q must not start with '$'", ErrUnsafeInput, key)
+	}
+	return nil
+}
+
+var (
+	fieldNameEscaper   = strings.NewReplacer("%", "%25", "$", "%24", ".", "%2E")
+	fieldNameUnescaper = strings.NewReplacer("%25", "%", "%24", "$", "%2E", ".")
+)
+
+// EscapeFieldName percent-escapes '$', '.', and '%' characters in name so that
+// any string, like a map key from user input, can be stored as a single field
+// name. Use UnescapeFieldName to recover the original name.
+func EscapeFieldName(name string) string {
+	return fieldNameEscaper.Replace(name)
+}
+
+func UnescapeFieldName(name string) string {
+	return fieldNameUnescaper.Replace(name)
+}
+
+// UntrustedField is like Field, but is safe to use with a name and value from
+// untrusted input. It returns an error if name is not a valid field name and
+// wraps value in $literal so it can't be interpreted as an expression.
+func UntrustedField(name string, value any) (FieldExpr, error) {
+	if err := ValidateFieldName(name); err != nil {
+		return FieldExpr{}, err
+	}
+	return Field(name, Literal(value)), nil
+}
+
+// UntrustedFieldPath returns a "$"-prefixed field path expression for path
+// from untrusted input, or an error if path is not a valid field path.
+func UntrustedFieldPath(path string) (string, error) {
+	if err := ValidateFieldPath(path); err != nil {
+		return "", err
+	}
+	return "$" + path, nil
+}
+
+// SanitizeMode is how a Sanitizer handles unsafe field names and values.
+type SanitizeMode int
+
+const (
+	// RejectUnsafe returns an error for unsafe field names and values.
+	RejectUnsafe SanitizeMode = iota
+	// EscapeUnsafe escapes unsafe field names with EscapeFieldName and wraps
+	// values so they're always treated as literals.
+	EscapeUnsafe
+)
+
+// Sanitizer is an opt-in mode for the stage and expression constructors that
+// take field names and values from untrusted input, like API parameters. Its
+// methods mirror the package constructors, but reject or escape field names
+// and values that could be interpreted as operators or paths to other fields.
+//
+// For example, to filter and sort by user-chosen fields:
+//
+//	s := agg.Sanitizer{Mode: agg.RejectUnsafe}
+//	match, err := s.Match(r.FormValue("field"), r.FormValue("value"))
+//	if err != nil {
+//		return err
+//	}
+//	sort, err := s.Sort(r.FormValue("sort"), true)
+type Sanitizer struct {
+	Mode SanitizeMode
+}
+
+// FieldName returns name if it's a safe single field name. In EscapeUnsafe
+// mode, unsafe names are escaped instead, but empty names are still an error.
+func (s Sanitizer) FieldName(name string) (string, error) {
+	if s.Mode == EscapeUnsafe && name != "" {
+		return EscapeFieldName(name), nil
+	}
+	return name, ValidateFieldName(name)
+}
+
+// FieldPath returns path if it's a safe dotted field path. In EscapeUnsafe
+// mode, each '.'-separated part is escaped instead, but empty parts are still
+// an error.
+func (s Sanitizer) FieldPath(path string) (string, error) {
+	if s.Mode != EscapeUnsafe {
+		return path, ValidateFieldPath(path)
+	}
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		if part == "" {
+			return "", fmt.Errorf("%w: field path %q must not have empty parts", ErrUnsafeInput, path)
+		}
+		parts[i] = EscapeFieldName(part)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// Value returns value for use in an aggregation expression. In RejectUnsafe
+// mode, it returns an error if value contains a '$'-prefixed document key (see
+// ValidateValue). Either way, the value is wrapped in $literal so strings like
+// "$password" aren't interpreted as field paths.
+func (s Sanitizer) Value(value any) (Operator, error) {
+	if s.Mode != EscapeUnsafe {
+		if err := ValidateValue(value); err != nil {
+			return nil, err
+		}
+	}
+	return Literal(value), nil
+}
+
+// Field is like the package Field, with the name checked by FieldName and the
+// value by Value.
+func (s Sanitizer) Field(name string, value any) (FieldExpr, error) {
+	name, err := s.FieldName(name)
+	if err != nil {
+		return FieldExpr{}, err
+	}
+	v, err := s.Value(value)
+	if err != nil {
+		return FieldExpr{}, err
+	}
+	return Field(name, v), nil
+}
+
+// FieldRef returns a "$"-prefixed field path expression for path, checked by
+// FieldPath.
+func (s Sanitizer) FieldRef(path string) (string, error) {
+	path, err := s.FieldPath(path)
+	if err != nil {
+		return "", err
+	}
+	return "$" + path, nil
+}
+
+// Match returns a $match stage for documents where the field at path equals
+// value. The value is compared with $eq, so a document value like
+// {"$ne": null} is matched literally instead of as a query operator. In
+// RejectUnsafe mode, such values are an error instead.
+func (s Sanitizer) Match(path string, value any) (Stage, error) {
+	path, err := s.FieldPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if s.Mode != EscapeUnsafe {
+		if err := ValidateValue(value); err != nil {
+			return nil, err
+		}
+	}
+	return Match(bson.D{{Key: path, Value: bson.D{{Key: "$eq", Value: value}}}}), nil
+}
+
+// Sort returns a $sort stage on the field at path, checked by FieldPath.
+func (s Sanitizer) Sort(path string, ascending bool) (Stage, error) {
+	path, err := s.FieldPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if ascending {
+		return Sort(SortAscending(path)), nil
+	}
+	return Sort(SortDescending(path)), nil
+}
+
+// Project returns an inclusion $project stage for the fields at paths, each
+// checked by FieldPath.
+func (s Sanitizer) Project(paths ...string) (Stage, error) {
+	fields := make([]FieldExpr, len(paths))
+	for i, p := range paths {
+		p, err := s.FieldPath(p)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = Field(p, 1)
+	}
+	return Project(fields...), nil
+}
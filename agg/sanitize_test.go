@@ -0,0 +1,72 @@
+package agg
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSanitizerMatch(t *testing.T) {
+	injection := bson.D{{Key: "$ne", Value: nil}}
+
+	tests := []struct {
+		name    string
+		mode    SanitizeMode
+		path    string
+		value   any
+		want    Stage
+		wantErr bool
+	}{
+		{
+			name:  "safe",
+			mode:  RejectUnsafe,
+			path:  "a.b",
+			value: "x",
+			want:  Match(bson.D{{Key: "a.b", Value: bson.D{{Key: "$eq", Value: "x"}}}}),
+		},
+		{name: "reject operator path", mode: RejectUnsafe, path: "$where", value: "x", wantErr: true},
+		{name: "reject empty path part", mode: EscapeUnsafe, path: "a..b", value: "x", wantErr: true},
+		{name: "reject operator value", mode: RejectUnsafe, path: "a", value: injection, wantErr: true},
+		{
+			name:  "escape",
+			mode:  EscapeUnsafe,
+			path:  "$where.b",
+			value: injection,
+			want:  Match(bson.D{{Key: "%24where.b", Value: bson.D{{Key: "$eq", Value: injection}}}}),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Sanitizer{Mode: tc.mode}.Match(tc.path, tc.value)
+			if tc.wantErr {
+				if !errors.Is(err, ErrUnsafeInput) {
+					t.Errorf("got error %v, want ErrUnsafeInput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizerField(t *testing.T) {
+	if _, err := (Sanitizer{}).Field("a.b", 1); !errors.Is(err, ErrUnsafeInput) {
+		t.Errorf("got error %v, want ErrUnsafeInput", err)
+	}
+
+	got, err := Sanitizer{Mode: EscapeUnsafe}.Field("a.b", "$secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Field("a%2Eb", Literal("$secret"))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -1,33 +1,135 @@
 package agg
 
-import "go.mongodb.org/mongo-driver/bson"
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
 
 type Stage = bson.D
 
 func AddFields(fields ...FieldExpr) Stage {
-	body := make(bson.D, 0, len(fields))
-	for _, field := range fields {
-		body = append(body, bson.E(field))
-	}
-	return bson.D{{Key: "$addFields", Value: body}}
+	return bson.D{{Key: "$addFields", Value: fieldExprsToD(fields)}}
 }
 
 func Count(fieldName string) Stage {
 	return bson.D{{Key: "$count", Value: fieldName}}
 }
 
-// TODO: Can we combine these?
+// Deprecated: Use acc.Count instead.
 func CountAccumulator() Operator {
 	return Operator{{Key: "$count", Value: bson.D{}}}
 }
 
+// DensifyRange specifies how $densify generates documents.
+type DensifyRange struct {
+	// Step is the amount to increment the field value by for each generated
+	// document.
+	Step any
+
+	// Unit is the time unit of Step if the field is a date. Must be empty if
+	// the field is a number.
+	Unit TimeUnit
+
+	// Bounds is "full", "partition", or a two-element array with the lower
+	// (inclusive) and upper (exclusive) bounds.
+	Bounds any
+}
+
+// Densify generates documents to fill in missing values of field in a
+// sequence, like a time series with missing intervals.
+func Densify(field string, partitionByFields []string, rng DensifyRange, opts ...DensifyOption) Stage {
+	for _, opt := range opts {
+		opt(&rng)
+	}
+
+	rangeBody := make(bson.D, 0, 3)
+	rangeBody = append(rangeBody, bson.E{Key: "step", Value: rng.Step})
+	if rng.Unit != "" {
+		rangeBody = append(rangeBody, bson.E{Key: "unit", Value: rng.Unit})
+	}
+	rangeBody = append(rangeBody, bson.E{Key: "bounds", Value: rng.Bounds})
+
+	body := make(bson.D, 0, 3)
+	body = append(body, bson.E{Key: "field", Value: field})
+	if len(partitionByFields) > 0 {
+		body = append(body, bson.E{
+			Key:   "partitionByFields",
+			Value: append([]string(nil), partitionByFields...),
+		})
+	}
+	body = append(body, bson.E{Key: "range", Value: rangeBody})
+
+	return Stage{{Key: "$densify", Value: body}}
+}
+
+// Facet runs multiple pipelines on the same input documents. Each facet's
+// value is the pipeline, and the output is a single document with the results
+// of each pipeline as an array. Use DecodeFacets to decode the output.
+func Facet(facets ...FieldExpr) Stage {
+	return Stage{{Key: "$facet", Value: fieldExprsToD(facets)}}
+}
+
+type FillMethod string
+
+const (
+	// LOCF fills null and missing values with the last non-null value.
+	LOCF FillMethod = "locf"
+	// Linear fills null and missing values using linear interpolation between
+	// the surrounding non-null values.
+	Linear FillMethod = "linear"
+)
+
+// FillBy returns a Fill output specification that fills values using method.
+func FillBy(method FillMethod) bson.D {
+	return bson.D{{Key: "method", Value: method}}
+}
+
+// FillValue returns a Fill output specification that fills values with the
+// result of expr.
+func FillValue(expr any) bson.D {
+	return bson.D{{Key: "value", Value: expr}}
+}
+
+// Fill populates null and missing field values. Each output field's value is
+// a FillBy or FillValue specification. The LOCF and Linear methods require
+// sortBy.
+func Fill(partitionByFields []string, sortBy []SortBy, output ...FieldExpr) Stage {
+	body := make(bson.D, 0, 3)
+	if len(partitionByFields) > 0 {
+		body = append(body, bson.E{
+			Key:   "partitionByFields",
+			Value: append([]string(nil), partitionByFields...),
+		})
+	}
+	if len(sortBy) > 0 {
+		body = append(body, bson.E{Key: "sortBy", Value: sortBysToD(sortBy)})
+	}
+	body = append(body, bson.E{Key: "output", Value: fieldExprsToD(output)})
+
+	return Stage{{Key: "$fill", Value: body}}
+}
+
+// GeoNear returns documents in order of nearest to farthest from the near
+// point (e.g. a geo.Point), recording the distance in distanceField. It must
+// be the first stage in a pipeline.
+func GeoNear(near any, distanceField string, opts ...GeoNearOption) Stage {
+	body := bson.D{
+		{Key: "near", Value: near},
+		{Key: "distanceField", Value: distanceField},
+		{Key: "spherical", Value: true},
+	}
+	for _, opt := range opts {
+		opt(&body)
+	}
+	return Stage{{Key: "$geoNear", Value: body}}
+}
+
 func Group(key any, accumulators ...FieldExpr) Stage {
-	body := bson.D{{
-		Key:   "_id",
-		Value: key,
-	}}
-	for _, acc := range accumulators {
-		body = append(body, bson.E(acc))
+	body := make(bson.D, len(accumulators)+1)
+	body[0] = bson.E{Key: "_id", Value: key}
+	for i := range accumulators {
+		body[i+1] = bson.E(accumulators[i])
 	}
 
 	return Stage{{
@@ -36,38 +138,138 @@ func Group(key any, accumulators ...FieldExpr) Stage {
 	}}
 }
 
-// TODO: Make this work with the filter builder?
+func Limit(n int64) Stage {
+	return Stage{{Key: "$limit", Value: n}}
+}
+
+func Lookup(from, localField, foreignField, as string) Stage {
+	return Stage{{
+		Key: "$lookup",
+		Value: bson.D{
+			{Key: "from", Value: from},
+			{Key: "localField", Value: localField},
+			{Key: "foreignField", Value: foreignField},
+			{Key: "as", Value: as},
+		},
+	}}
+}
+
+func LookupPipeline(from string, let []FieldExpr, pipeline any, as string) Stage {
+	body := make(bson.D, 0, 4)
+	body = append(body, bson.E{Key: "from", Value: from})
+	if len(let) > 0 {
+		body = append(body, bson.E{Key: "let", Value: fieldExprsToD(let)})
+	}
+	body = append(body,
+		bson.E{Key: "pipeline", Value: pipeline},
+		bson.E{Key: "as", Value: as})
+
+	return Stage{{Key: "$lookup", Value: body}}
+}
+
+// Match filters documents with the given query filter. Aggregation
+// expressions (an Operator or BoolExpr) are wrapped in $expr, so
+//
+//	agg.Match(agg.Expr(agg.Eq("$a", "$b")))
+//
+// matches documents where field "a" is equal to field "b".
 func Match(query any) Stage {
+	switch query.(type) {
+	case Operator, BoolExpr:
+		query = bson.D{{Key: "$expr", Value: query}}
+	}
 	return Stage{{Key: "$match", Value: query}}
 }
 
-func Project(specifications ...FieldExpr) Stage {
-	body := make(bson.D, 0, len(specifications))
-	for _, spec := range specifications {
-		body = append(body, bson.E(spec))
+// Merge writes the pipeline results into the "into" collection, which is a
+// collection name or a {db, coll} document. Options that aren't set are
+// omitted, which uses the server defaults (on "_id", whenMatched "merge", and
+// whenNotMatched "insert").
+func Merge(into any, opts ...MergeOption) Stage {
+	body := make(bson.D, 0, 5)
+	body = append(body, bson.E{Key: "into", Value: into})
+	for _, opt := range opts {
+		opt(&body)
+	}
+
+	return Stage{{Key: "$merge", Value: body}}
+}
+
+// Out writes the pipeline results to the "into" collection, which is a
+// collection name or a {db, coll} document, replacing the collection if it
+// exists. It must be the last stage in a pipeline. Use OutTo to set options.
+func Out(into any) Stage {
+	return Stage{{Key: "$out", Value: into}}
+}
+
+// OutTo is like Out, but writes to the coll collection in the db database
+// with the given options. The document form of $out that supports options
+// requires a database name, so OutTo returns an error if db or coll is
+// empty.
+func OutTo(db, coll string, opts ...OutOption) (Stage, error) {
+	if db == "" || coll == "" {
+		return nil, fmt.Errorf("$out database and collection must not be empty, got db %q and coll %q", db, coll)
+	}
+	body := bson.D{{Key: "db", Value: db}, {Key: "coll", Value: coll}}
+	for _, opt := range opts {
+		opt(&body)
 	}
+	return Stage{{Key: "$out", Value: body}}, nil
+}
 
+func Project(specifications ...FieldExpr) Stage {
 	return Stage{{
 		Key:   "$project",
-		Value: body,
+		Value: fieldExprsToD(specifications),
 	}}
 }
 
+// ReplaceWith replaces each document with the document replacementExpr
+// evaluates to.
+func ReplaceWith(replacementExpr any) Stage {
+	return Stage{{Key: "$replaceWith", Value: replacementExpr}}
+}
+
+// Set is an alias of AddFields.
+func Set(fields ...FieldExpr) Stage {
+	return Stage{{Key: "$set", Value: fieldExprsToD(fields)}}
+}
+
+func Skip(n int64) Stage {
+	return Stage{{Key: "$skip", Value: n}}
+}
+
 func Sort(sortBys ...SortBy) Stage {
 	return Stage{{Key: "$sort", Value: sortBysToD(sortBys)}}
 }
 
+// UnionWith adds the results of running pipeline on the coll collection to
+// the pipeline's results. If pipeline is nil, all documents in coll are added.
+func UnionWith(coll string, pipeline any) Stage {
+	if pipeline == nil {
+		return Stage{{Key: "$unionWith", Value: coll}}
+	}
+	return Stage{{
+		Key: "$unionWith",
+		Value: bson.D{
+			{Key: "coll", Value: coll},
+			{Key: "pipeline", Value: pipeline},
+		},
+	}}
+}
+
 func Unset(fields ...string) Stage {
-	return Stage{{Key: "$unset", Value: fields}}
+	return Stage{{Key: "$unset", Value: append([]string(nil), fields...)}}
 }
 
-// TODO: Support optional behaviors?
-func Unwind(fieldPath string) Stage {
-	return Stage{{
-		Key: "$unwind",
-		Value: bson.D{{
-			Key:   "path",
-			Value: fieldPath,
-		}},
-	}}
+// Unwind outputs a document for each element of the array at fieldPath, with
+// the array replaced by the element. For example:
+//
+//	agg.Unwind("$items", agg.WithIncludeArrayIndex("itemIndex"))
+func Unwind(fieldPath string, opts ...UnwindOption) Stage {
+	body := bson.D{{Key: "path", Value: fieldPath}}
+	for _, opt := range opts {
+		opt(&body)
+	}
+	return Stage{{Key: "$unwind", Value: body}}
 }
@@ -0,0 +1,66 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestOutTo(t *testing.T) {
+	got, err := OutTo("reports", "daily", WithTimeseries("ts", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Stage{{Key: "$out", Value: bson.D{
+		{Key: "db", Value: "reports"},
+		{Key: "coll", Value: "daily"},
+		{Key: "timeseries", Value: bson.D{{Key: "timeField", Value: "ts"}}},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, ns := range [][2]string{{"", "daily"}, {"reports", ""}} {
+		if _, err := OutTo(ns[0], ns[1], WithTimeseries("ts", "")); err == nil {
+			t.Errorf("expected an error for db %q and coll %q", ns[0], ns[1])
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Stage
+		want bson.D
+	}{
+		{
+			name: "defaults",
+			s:    Merge("totals"),
+			want: bson.D{{Key: "into", Value: "totals"}},
+		},
+		{
+			name: "options",
+			s: Merge("totals",
+				WithMergeOn("day", "store"),
+				WithMergeLet(Field("n", "$count")),
+				WithWhenMatched(Pipeline{AddFields(Field("n", "$$n"))}),
+				WithWhenNotMatched("discard")),
+			want: bson.D{
+				{Key: "into", Value: "totals"},
+				{Key: "on", Value: []string{"day", "store"}},
+				{Key: "let", Value: bson.D{{Key: "n", Value: "$count"}}},
+				{Key: "whenMatched", Value: Pipeline{AddFields(Field("n", "$$n"))}},
+				{Key: "whenNotMatched", Value: "discard"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			want := Stage{{Key: "$merge", Value: tc.want}}
+			if !reflect.DeepEqual(tc.s, want) {
+				t.Errorf("got %v, want %v", tc.s, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package agg
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Stage options set optional fields of the stage body document. Each stage has
+// its own option type so options can't be passed to the wrong stage
+// constructor.
+
+type UnwindOption func(body *bson.D)
+
+// WithIncludeArrayIndex records the array index of each unwound element in
+// the field.
+func WithIncludeArrayIndex(field string) UnwindOption {
+	return func(body *bson.D) {
+		setElem(body, "includeArrayIndex", field)
+	}
+}
+
+// WithPreserveNullAndEmptyArrays outputs documents where the path is null,
+// missing, or an empty array instead of dropping them.
+func WithPreserveNullAndEmptyArrays() UnwindOption {
+	return func(body *bson.D) {
+		setElem(body, "preserveNullAndEmptyArrays", true)
+	}
+}
+
+type MergeOption func(body *bson.D)
+
+// WithMergeOn sets the fields that identify the existing document a result
+// matches. Fields other than "_id" must have a unique index.
+func WithMergeOn(fields ...string) MergeOption {
+	on := append([]string(nil), fields...)
+	return func(body *bson.D) {
+		setElem(body, "on", append([]string(nil), on...))
+	}
+}
+
+// WithWhenMatched sets the action for results that match an existing
+// document: "merge", "replace", "keepExisting", "fail", or an update
+// pipeline.
+func WithWhenMatched(action any) MergeOption {
+	action = cloneValue(action)
+	return func(body *bson.D) {
+		setElem(body, "whenMatched", cloneValue(action))
+	}
+}
+
+// WithWhenNotMatched sets the action for results that don't match an existing
+// document: "insert", "discard", or "fail".
+func WithWhenNotMatched(action string) MergeOption {
+	return func(body *bson.D) {
+		setElem(body, "whenNotMatched", action)
+	}
+}
+
+// WithMergeLet declares variables that can be referenced as "$$<name>" in a
+// whenMatched pipeline.
+func WithMergeLet(vars ...FieldExpr) MergeOption {
+	let := fieldExprsToD(vars)
+	return func(body *bson.D) {
+		setElem(body, "let", cloneD(let))
+	}
+}
+
+type OutOption func(body *bson.D)
+
+// WithTimeseries writes the output to a time series collection with the given
+// time field and, if not empty, meta field. The output collection must not
+// exist or must already be a time series collection.
+func WithTimeseries(timeField, metaField string) OutOption {
+	return func(body *bson.D) {
+		ts := bson.D{{Key: "timeField", Value: timeField}}
+		if metaField != "" {
+			ts = append(ts, bson.E{Key: "metaField", Value: metaField})
+		}
+		setElem(body, "timeseries", ts)
+	}
+}
+
+type GeoNearOption func(body *bson.D)
+
+// WithMaxDistance limits results to documents within distance of the near
+// point, in meters for GeoJSON points or radians for legacy coordinates.
+func WithMaxDistance(distance float64) GeoNearOption {
+	return func(body *bson.D) {
+		setElem(body, "maxDistance", distance)
+	}
+}
+
+// WithMinDistance limits results to documents at least distance from the
+// near point.
+func WithMinDistance(distance float64) GeoNearOption {
+	return func(body *bson.D) {
+		setElem(body, "minDistance", distance)
+	}
+}
+
+// WithGeoQuery limits results to documents that match the query filter.
+func WithGeoQuery(filter any) GeoNearOption {
+	return func(body *bson.D) {
+		setElem(body, "query", filter)
+	}
+}
+
+// WithGeoKey sets the geospatial indexed field to use when the collection has
+// more than one geospatial index.
+func WithGeoKey(field string) GeoNearOption {
+	return func(body *bson.D) {
+		setElem(body, "key", field)
+	}
+}
+
+// WithIncludeLocs records the location used to calculate the distance in the
+// field.
+func WithIncludeLocs(field string) GeoNearOption {
+	return func(body *bson.D) {
+		setElem(body, "includeLocs", field)
+	}
+}
+
+// WithDistanceMultiplier multiplies all returned distances by factor, e.g. to
+// convert radians to kilometers.
+func WithDistanceMultiplier(factor float64) GeoNearOption {
+	return func(body *bson.D) {
+		setElem(body, "distanceMultiplier", factor)
+	}
+}
+
+// WithSpherical sets whether distances are calculated using spherical
+// geometry. GeoNear uses spherical geometry by default.
+func WithSpherical(spherical bool) GeoNearOption {
+	return func(body *bson.D) {
+		setElem(body, "spherical", spherical)
+	}
+}
+
+// setElem sets the value of key in d, appending it if it doesn't exist.
+func setElem(d *bson.D, key string, value any) {
+	for i := range *d {
+		if (*d)[i].Key == key {
+			(*d)[i].Value = value
+			return
+		}
+	}
+	*d = append(*d, bson.E{Key: key, Value: value})
+}
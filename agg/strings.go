@@ -0,0 +1,80 @@
+package agg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConcatSep concatenates the string expressions with sep between each of them.
+func ConcatSep(sep string, exprs ...any) Operator {
+	args := make([]any, 0, 2*len(exprs))
+	for i, expr := range exprs {
+		if i > 0 {
+			args = append(args, stringLiteral(sep))
+		}
+		args = append(args, expr)
+	}
+	return Concat(args...)
+}
+
+// Template returns a $concat expression that expands the "{field}"
+// placeholders in tmpl to the string value of the referenced field. Field
+// names are prefixed with '$' if they aren't already, so variables can be
+// referenced like "{$$item.name}". Use "{{" and "}}" for literal braces.
+//
+// For example:
+//
+//	agg.Template("{name} ({address.city})")
+//
+// builds
+//
+//	{$concat: [{$toString: "$name"}, " (", {$toString: "$address.city"}, ")"]}
+//
+// The result is null if any referenced field is null or missing.
+func Template(tmpl string) (Operator, error) {
+	var args []any
+	var lit strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case c == '{' && strings.HasPrefix(tmpl[i:], "{{"):
+			lit.WriteByte('{')
+			i++
+		case c == '}' && strings.HasPrefix(tmpl[i:], "}}"):
+			lit.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed placeholder at offset %d in template %q", i, tmpl)
+			}
+			field := tmpl[i+1 : i+end]
+			if field == "" {
+				return nil, fmt.Errorf("empty placeholder at offset %d in template %q", i, tmpl)
+			}
+			if lit.Len() > 0 {
+				args = append(args, stringLiteral(lit.String()))
+				lit.Reset()
+			}
+			args = append(args, ToString(fieldPathValue(field)))
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("unexpected '}' at offset %d in template %q", i, tmpl)
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	if lit.Len() > 0 {
+		args = append(args, stringLiteral(lit.String()))
+	}
+	return Concat(args...), nil
+}
+
+// stringLiteral wraps s in $literal if it would otherwise be interpreted as a
+// field path or variable.
+func stringLiteral(s string) any {
+	if strings.HasPrefix(s, "$") {
+		return Literal(s)
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package agg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FieldsFromStruct returns a FieldExpr for each exported field of the struct
+// (or pointer to struct) v, so a $project or $addFields shape can be declared
+// once as a Go type and reused to decode the results. Output field names come
+// from the "bson" struct tags. Each field's expression is, in order of
+// precedence:
+//
+//   - The "agg" struct tag. Tags starting with '$' are used as a field path or
+//     variable string; all other tags are parsed as a relaxed Extended JSON
+//     value (e.g. `agg:"0"` or `agg:"{\"$toUpper\": \"$name\"}"`).
+//   - The struct field's value, if it is not the zero value.
+//   - 1, which includes the field in a $project stage.
+//
+// For example:
+//
+//	type summary struct {
+//		ID    any          `bson:"_id" agg:"0"`
+//		Name  string       `bson:"name"`
+//		Total agg.Operator `bson:"total"`
+//	}
+//	fields, err := agg.FieldsFromStruct(summary{Total: agg.Sum("$items.price")})
+//	...
+//	agg.Project(fields...)
+func FieldsFromStruct(v any) ([]FieldExpr, error) {
+	fields, err := structFields(v)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := make([]FieldExpr, len(fields))
+	for i, f := range fields {
+		var value any = 1
+		switch {
+		case f.tag != "":
+			value, err = parseExprTag(f.tag)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing \"agg\" tag for field %q: %w", f.name, err)
+			}
+		case !f.value.IsZero():
+			value = f.value.Interface()
+		}
+		exprs[i] = Field(f.name, value)
+	}
+	return exprs, nil
+}
+
+func parseExprTag(tag string) (any, error) {
+	if strings.HasPrefix(tag, "$") {
+		return tag, nil
+	}
+
+	var doc bson.D
+	err := bson.UnmarshalExtJSON([]byte(`{"v":`+tag+`}`), false, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc[0].Value, nil
+}
+
+type structField struct {
+	name  string
+	tag   string
+	value reflect.Value
+}
+
+// structFields returns the exported fields of the struct (or pointer to
+// struct) v, named according to their "bson" struct tags. Fields tagged with
+// "-" are skipped and untagged fields use the lowercased Go field name, the
+// same as the default BSON struct codec.
+func structFields(v any) ([]structField, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("expected a struct, got nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %T", v)
+	}
+
+	rt := rv.Type()
+	fields := make([]structField, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := strings.ToLower(sf.Name)
+		if tag, ok := sf.Tag.Lookup("bson"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fields = append(fields, structField{
+			name:  name,
+			tag:   sf.Tag.Get("agg"),
+			value: rv.Field(i),
+		})
+	}
+	return fields, nil
+}
@@ -0,0 +1,25 @@
+package agg
+
+import "github.com/matthewdale/mongo-go-exp/query"
+
+// TextScore returns the relevance score of a $text search match.
+func TextScore() Operator {
+	return Meta("textScore")
+}
+
+// SortByTextScore sorts documents by $text search relevance score, most
+// relevant first.
+func SortByTextScore(fieldName string) SortBy {
+	return SortExpr(fieldName, TextScore())
+}
+
+// TextSearch returns the stages for a $text search that records each
+// matching document's relevance score in scoreField and sorts the documents
+// by it, most relevant first. The opts can be nil.
+func TextSearch(search string, opts *query.TextOptions, scoreField string) Pipeline {
+	return Pipeline{
+		Match(query.Text(search, opts)),
+		AddFields(Field(scoreField, TextScore())),
+		Sort(SortByTextScore(scoreField)),
+	}
+}
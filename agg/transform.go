@@ -0,0 +1,107 @@
+package agg
+
+// PipelineTransformer modifies pipelines before they're run, to apply
+// cross-cutting concerns like tenant scoping uniformly. Transformers must not
+// modify the input pipeline in place; the Pipeline editing methods (e.g.
+// InsertBefore) always return a new pipeline.
+type PipelineTransformer interface {
+	Transform(p Pipeline) (Pipeline, error)
+}
+
+// TransformerFunc adapts a function to a PipelineTransformer.
+type TransformerFunc func(p Pipeline) (Pipeline, error)
+
+func (f TransformerFunc) Transform(p Pipeline) (Pipeline, error) {
+	return f(p)
+}
+
+// Chain returns a transformer that applies each transformer in order, stopping
+// at the first error.
+func Chain(transformers ...PipelineTransformer) PipelineTransformer {
+	transformers = append([]PipelineTransformer(nil), transformers...)
+	return TransformerFunc(func(p Pipeline) (Pipeline, error) {
+		var err error
+		for _, t := range transformers {
+			p, err = t.Transform(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return p, nil
+	})
+}
+
+// Transform applies transformers to p in order. It's shorthand for
+// Chain(transformers...).Transform(p).
+func (p Pipeline) Transform(transformers ...PipelineTransformer) (Pipeline, error) {
+	return Chain(transformers...).Transform(p)
+}
+
+// ScopeTo returns a transformer that restricts the pipeline's input documents
+// to those matching filter, like {tenantId: "acme"}. The $match is inserted as
+// early as possible, after any stages that must be first (e.g. $geoNear) and
+// the comment tag (see Named).
+//
+// Only the top-level pipeline is scoped. Collections read by $lookup,
+// $graphLookup, and $unionWith stages are not filtered.
+func ScopeTo(filter any) PipelineTransformer {
+	return TransformerFunc(func(p Pipeline) (Pipeline, error) {
+		return p.InsertBefore(leadingStagesEnd(p), Match(filter)), nil
+	})
+}
+
+// LimitTo returns a transformer that caps the number of documents the pipeline
+// returns or writes at n. The $limit is added before any final output stage
+// (e.g. $merge).
+func LimitTo(n int64) PipelineTransformer {
+	return TransformerFunc(func(p Pipeline) (Pipeline, error) {
+		return p.InsertBefore(outputStageStart(p), Limit(n)), nil
+	})
+}
+
+// RedactFields returns a transformer that removes fields from the pipeline's
+// output documents with $unset, before any final output stage.
+func RedactFields(fields ...string) PipelineTransformer {
+	fields = append([]string(nil), fields...)
+	return TransformerFunc(func(p Pipeline) (Pipeline, error) {
+		if len(fields) == 0 {
+			return p, nil
+		}
+		return p.InsertBefore(outputStageStart(p), Unset(fields...)), nil
+	})
+}
+
+// leadingStagesEnd returns the index after the stages at the start of p that
+// must be first in a pipeline and the comment tag that follows them, if any.
+func leadingStagesEnd(p Pipeline) int {
+	if i, ok := p.commentIndex(); ok {
+		return i + 1
+	}
+	return firstStagesEnd(p)
+}
+
+// firstStagesEnd returns the index after the stages at the start of p that
+// must be first in a pipeline.
+func firstStagesEnd(p Pipeline) int {
+	i := 0
+	for i < len(p) {
+		info, ok := LookupStage(stageName(p[i]))
+		if !ok || !info.First {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// outputStageStart returns the index of the final stage of p if it must be
+// last in a pipeline, like $out and $merge, or len(p) otherwise.
+func outputStageStart(p Pipeline) int {
+	if len(p) == 0 {
+		return 0
+	}
+	if info, ok := LookupStage(stageName(p[len(p)-1])); ok && info.Last {
+		return len(p) - 1
+	}
+	return len(p)
+}
@@ -0,0 +1,56 @@
+package agg
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScopeTo(t *testing.T) {
+	filter := bson.D{{Key: "tenant", Value: "acme"}}
+	near := GeoNear(bson.D{}, "dist")
+
+	tests := []struct {
+		name string
+		p    Pipeline
+		want Pipeline
+	}{
+		{
+			name: "plain",
+			p:    Pipeline{Limit(1)},
+			want: Pipeline{Match(filter), Limit(1)},
+		},
+		{
+			name: "after $geoNear",
+			p:    Pipeline{near, Limit(1)},
+			want: Pipeline{near, Match(filter), Limit(1)},
+		},
+		{
+			name: "after comment",
+			p:    Named("x", Limit(1)),
+			want: Pipeline{CommentStage("x"), Match(filter), Limit(1)},
+		},
+		{
+			name: "after $geoNear and comment",
+			p:    Named("x", near, Limit(1)),
+			want: Pipeline{near, CommentStage("x"), Match(filter), Limit(1)},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.p.Transform(ScopeTo(filter))
+			if err != nil {
+				t.Fatalf("error transforming: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+			if _, ok := tc.p.Comment(); ok {
+				if _, ok := got.Comment(); !ok {
+					t.Errorf("transformed pipeline lost its comment tag")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package agg
+
+// UnionAll returns a pipeline that runs pipeline on the collection it's
+// aggregated against and on each of colls, combining the results with a chain
+// of $unionWith stages. It's useful for querying data split across
+// collections, like per-month collections:
+//
+//	p := agg.UnionAll(pipeline, "events_2024_02", "events_2024_03")
+//	cursor, err := agg.Aggregate(ctx, db.Collection("events_2024_01"), p)
+//
+// Stages added after UnionAll apply to the combined results.
+func UnionAll(pipeline Pipeline, colls ...string) Pipeline {
+	p := make(Pipeline, 0, len(pipeline)+len(colls))
+	p = append(p, pipeline.Clone()...)
+	for _, coll := range colls {
+		p = append(p, UnionWith(coll, nonNilPipeline(pipeline.Clone())))
+	}
+	return p
+}
@@ -0,0 +1,50 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// updateStages are the stages allowed in an update pipeline.
+var updateStages = map[string]bool{
+	"$addFields":   true,
+	"$set":         true,
+	"$project":     true,
+	"$unset":       true,
+	"$replaceRoot": true,
+	"$replaceWith": true,
+}
+
+// UpdatePipeline returns stages as a pipeline that can be passed as the update
+// document to Collection.UpdateOne and Collection.UpdateMany. It returns an
+// error if any stage isn't allowed in an update pipeline; only $addFields,
+// $set, $project, $unset, $replaceRoot, and $replaceWith are. A leading
+// comment tag (see Named) is removed, since comments can't be sent as stages
+// in an update.
+//
+// For example, to compute a field from other fields of each document:
+//
+//	update, err := agg.UpdatePipeline(
+//		agg.Set(agg.Field("total", agg.Add("$subtotal", "$tax"))),
+//		agg.Unset("draft"))
+//	if err != nil {
+//		return err
+//	}
+//	_, err = coll.UpdateMany(ctx, filter, update)
+func UpdatePipeline(stages ...Stage) (Pipeline, error) {
+	p := Pipeline(stages)
+	if i, ok := p.commentIndex(); ok {
+		p = p.RemoveStage(i)
+	}
+	if len(p) == 0 {
+		return nil, errors.New("update pipeline must have at least one stage")
+	}
+	for i, s := range p {
+		if name := stageName(s); !updateStages[name] {
+			return nil, fmt.Errorf(
+				"stage %d: %q isn't allowed in an update pipeline; use $addFields, $set, $project, $unset, $replaceRoot, or $replaceWith",
+				i, name)
+		}
+	}
+	return p.Clone(), nil
+}
@@ -0,0 +1,48 @@
+package agg
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateView creates a read-only view named viewName that shows the results
+// of running pipeline on the source collection or view.
+func CreateView(
+	ctx context.Context,
+	db *mongo.Database,
+	viewName string,
+	source string,
+	pipeline Pipeline,
+	opts ...*options.CreateViewOptions,
+) error {
+	return db.CreateView(ctx, viewName, source, nonNilPipeline(pipeline), opts...)
+}
+
+// UpdateViewPipeline replaces the source and pipeline of the existing view
+// named viewName using the collMod command.
+func UpdateViewPipeline(
+	ctx context.Context,
+	db *mongo.Database,
+	viewName string,
+	source string,
+	pipeline Pipeline,
+) error {
+	cmd := bson.D{
+		{Key: "collMod", Value: viewName},
+		{Key: "viewOn", Value: source},
+		{Key: "pipeline", Value: nonNilPipeline(pipeline)},
+	}
+	return db.RunCommand(ctx, cmd).Err()
+}
+
+// nonNilPipeline returns an empty pipeline if p is nil, because a nil slice
+// marshals as BSON null, which the server rejects.
+func nonNilPipeline(p Pipeline) Pipeline {
+	if p == nil {
+		return Pipeline{}
+	}
+	return p
+}
@@ -0,0 +1,272 @@
+package agg
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// stageName returns the name of the stage, like "$match", or an empty string
+// if the stage is not a single-field document.
+func stageName(s Stage) string {
+	if len(s) != 1 {
+		return ""
+	}
+	return s[0].Key
+}
+
+// stageBody returns the value of the stage's single field, or nil if the
+// stage is not a single-field document.
+func stageBody(s Stage) any {
+	if len(s) != 1 {
+		return nil
+	}
+	return s[0].Value
+}
+
+// lookupKey returns the value of key in the document v, which can be a bson.D,
+// Operator, or bson.M.
+func lookupKey(v any, key string) (any, bool) {
+	switch v := v.(type) {
+	case bson.D:
+		for _, e := range v {
+			if e.Key == key {
+				return e.Value, true
+			}
+		}
+	case Operator:
+		for _, e := range v {
+			if e.Key == key {
+				return e.Value, true
+			}
+		}
+	case bson.M:
+		e, ok := v[key]
+		return e, ok
+	case map[string]any:
+		e, ok := v[key]
+		return e, ok
+	}
+	return nil, false
+}
+
+// docElems returns the elements of the document v, which can be a bson.D,
+// Operator, or bson.M. Elements of a bson.M are sorted by key.
+func docElems(v any) (bson.D, bool) {
+	switch v := v.(type) {
+	case bson.D:
+		return v, true
+	case Operator:
+		return bson.D(v), true
+	case bson.M:
+		return sortedD(v), true
+	case map[string]any:
+		return sortedD(v), true
+	}
+	return nil, false
+}
+
+func sortedD(m map[string]any) bson.D {
+	d := make(bson.D, 0, len(m))
+	for k, v := range m {
+		d = append(d, bson.E{Key: k, Value: v})
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i].Key < d[j].Key })
+	return d
+}
+
+// walkValue calls fn for v and, recursively, every value nested in documents
+// and arrays in v. Nested values are not visited if fn returns false.
+func walkValue(v any, fn func(v any) bool) {
+	if !fn(v) {
+		return
+	}
+
+	switch v := v.(type) {
+	case BoolExpr:
+		walkValue(v.expr, fn)
+	case FieldExpr:
+		walkValue(v.Value, fn)
+	case SortBy:
+		walkValue(v.Value, fn)
+	case bson.E:
+		walkValue(v.Value, fn)
+	case bson.A:
+		for _, e := range v {
+			walkValue(e, fn)
+		}
+	case []any:
+		for _, e := range v {
+			walkValue(e, fn)
+		}
+	case Pipeline:
+		for _, s := range v {
+			walkValue(s, fn)
+		}
+	case []bson.D:
+		for _, s := range v {
+			walkValue(s, fn)
+		}
+	default:
+		if d, ok := docElems(v); ok {
+			for _, e := range d {
+				walkValue(e.Value, fn)
+			}
+		}
+	}
+}
+
+// fieldPathRefs returns the field paths referenced by "$<path>" strings in v,
+// without the "$" prefix. Variables like "$$ROOT" are ignored.
+func fieldPathRefs(v any) []string {
+	var refs []string
+	walkValue(v, func(v any) bool {
+		if s, ok := v.(string); ok && isFieldPath(s) {
+			refs = append(refs, s[1:])
+		}
+		return true
+	})
+	return refs
+}
+
+func isFieldPath(s string) bool {
+	return len(s) > 1 && s[0] == '$' && s[1] != '$'
+}
+
+// filterFields returns the field paths used as keys in the query filter,
+// including those nested in top-level $and, $or, and $nor clauses.
+func filterFields(filter any) []string {
+	d, ok := docElems(filter)
+	if !ok {
+		return nil
+	}
+
+	var fields []string
+	for _, e := range d {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			walkValue(e.Value, func(v any) bool {
+				if _, ok := docElems(v); ok {
+					fields = append(fields, filterFields(v)...)
+					return false
+				}
+				return true
+			})
+		default:
+			if !strings.HasPrefix(e.Key, "$") {
+				fields = append(fields, e.Key)
+			}
+		}
+	}
+	return fields
+}
+
+// topField returns the first part of the dotted field path.
+func topField(path string) string {
+	top, _, _ := strings.Cut(path, ".")
+	return top
+}
+
+// projectionPaths returns the paths of the fields a $project specification
+// includes or excludes with a number or boolean, including those in nested
+// specifications like {a: {b: 1}}, which includes "a.b". Computed fields are
+// not returned.
+func projectionPaths(body any) []string {
+	var paths []string
+	for _, e := range projectionSpec(body) {
+		if isProjectionFlag(e.Value) {
+			paths = append(paths, e.Key)
+		}
+	}
+	return paths
+}
+
+// stageFieldRefs returns the input field paths the stage reads.
+func stageFieldRefs(s Stage) []string {
+	body := stageBody(s)
+	refs := fieldPathRefs(body)
+
+	switch stageName(s) {
+	case "$match":
+		refs = append(refs, filterFields(body)...)
+	case "$sort":
+		if d, ok := docElems(body); ok {
+			for _, e := range d {
+				refs = append(refs, e.Key)
+			}
+		}
+	case "$lookup":
+		if lf, ok := lookupKey(body, "localField"); ok {
+			if s, ok := lf.(string); ok {
+				refs = append(refs, s)
+			}
+		}
+	case "$densify", "$fill", "$setWindowFields":
+		if f, ok := lookupKey(body, "field"); ok {
+			if s, ok := f.(string); ok {
+				refs = append(refs, s)
+			}
+		}
+		if pf, ok := lookupKey(body, "partitionByFields"); ok {
+			if fields, ok := pf.([]string); ok {
+				refs = append(refs, fields...)
+			}
+		}
+		if sb, ok := lookupKey(body, "sortBy"); ok {
+			if d, ok := docElems(sb); ok {
+				for _, e := range d {
+					refs = append(refs, e.Key)
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// stageSubPipelines returns the sub-pipelines nested in the stage, like the
+// pipelines of $facet, $lookup, and $unionWith.
+func stageSubPipelines(s Stage) []Pipeline {
+	body := stageBody(s)
+	var subs []Pipeline
+	switch stageName(s) {
+	case "$facet":
+		if d, ok := docElems(body); ok {
+			for _, e := range d {
+				if p, ok := toPipeline(e.Value); ok {
+					subs = append(subs, p)
+				}
+			}
+		}
+	case "$lookup", "$unionWith":
+		if v, ok := lookupKey(body, "pipeline"); ok {
+			if p, ok := toPipeline(v); ok {
+				subs = append(subs, p)
+			}
+		}
+	}
+	return subs
+}
+
+// toPipeline converts v to a Pipeline if it's a slice of stage documents.
+func toPipeline(v any) (Pipeline, bool) {
+	switch v := v.(type) {
+	case Pipeline:
+		return v, true
+	case []bson.D:
+		return Pipeline(v), true
+	case bson.A:
+		p := make(Pipeline, 0, len(v))
+		for _, e := range v {
+			d, ok := docElems(e)
+			if !ok {
+				return nil, false
+			}
+			p = append(p, Stage(d))
+		}
+		return p, true
+	case []any:
+		return toPipeline(bson.A(v))
+	}
+	return nil, false
+}
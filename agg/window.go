@@ -0,0 +1,217 @@
+package agg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+type TimeUnit string
+
+const (
+	Year        TimeUnit = "year"
+	Quarter     TimeUnit = "quarter"
+	Month       TimeUnit = "month"
+	Week        TimeUnit = "week"
+	Day         TimeUnit = "day"
+	Hour        TimeUnit = "hour"
+	Minute      TimeUnit = "minute"
+	Second      TimeUnit = "second"
+	Millisecond TimeUnit = "millisecond"
+)
+
+// WindowBound is the lower or upper bound of a $setWindowFields window. Use
+// Unbounded, Current, or Offset.
+type WindowBound struct {
+	sentinel string
+	offset   int64
+}
+
+var (
+	// Unbounded is the first document in the partition when used as a lower
+	// bound and the last document when used as an upper bound.
+	Unbounded = WindowBound{sentinel: "unbounded"}
+	// Current is the current document.
+	Current = WindowBound{sentinel: "current"}
+)
+
+// Offset is a position relative to the current document. For document
+// windows, it's a number of documents; for range windows, it's a difference
+// in the sortBy field value (in the window's time unit, if any).
+func Offset(n int64) WindowBound {
+	return WindowBound{offset: n}
+}
+
+func (wb WindowBound) value() any {
+	if wb.sentinel != "" {
+		return wb.sentinel
+	}
+	return wb.offset
+}
+
+// position returns the bound's position relative to the current document,
+// treating "unbounded" as minus or plus infinity depending on whether it's a
+// lower or upper bound.
+func (wb WindowBound) position(lower bool) float64 {
+	switch wb.sentinel {
+	case "unbounded":
+		if lower {
+			return math.Inf(-1)
+		}
+		return math.Inf(1)
+	case "current":
+		return 0
+	}
+	return float64(wb.offset)
+}
+
+// WindowBounds is a $setWindowFields window definition. Use Documents or
+// Range to create one.
+type WindowBounds struct {
+	kind  string
+	lower WindowBound
+	upper WindowBound
+	unit  TimeUnit
+}
+
+// Documents returns a window of documents positioned relative to the current
+// document.
+func Documents(lower, upper WindowBound) WindowBounds {
+	return WindowBounds{kind: "documents", lower: lower, upper: upper}
+}
+
+// Range returns a window of documents whose sortBy field values are within
+// the range relative to the current document's sortBy field value. If unit is
+// empty, the range is numeric; otherwise the sortBy field must be a date.
+func Range(lower, upper WindowBound, unit TimeUnit) WindowBounds {
+	return WindowBounds{kind: "range", lower: lower, upper: upper, unit: unit}
+}
+
+// Validate returns an error if the window bounds are invalid.
+func (wb WindowBounds) Validate() error {
+	switch wb.kind {
+	case "documents":
+		if wb.unit != "" {
+			return errors.New("documents window must not have a time unit")
+		}
+	case "range":
+	default:
+		return errors.New("window bounds must be created with Documents or Range")
+	}
+	if wb.lower.position(true) > wb.upper.position(false) {
+		return fmt.Errorf("window lower bound %v must not be after upper bound %v",
+			wb.lower.value(),
+			wb.upper.value())
+	}
+	return nil
+}
+
+func (wb WindowBounds) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if err := wb.Validate(); err != nil {
+		return 0, nil, err
+	}
+
+	d := bson.D{{Key: wb.kind, Value: bson.A{wb.lower.value(), wb.upper.value()}}}
+	if wb.unit != "" {
+		d = append(d, bson.E{Key: "unit", Value: wb.unit})
+	}
+	return bson.MarshalValue(d)
+}
+
+// Window returns the window operator op (e.g. acc.Sum) applied over the
+// window bounds, for use as a SetWindowFields output field.
+func Window(op Operator, bounds WindowBounds) Operator {
+	w := make(Operator, 0, len(op)+1)
+	w = append(w, op...)
+	return append(w, bson.E{Key: "window", Value: bounds})
+}
+
+func SetWindowFields(partitionBy any, sortBy []SortBy, output ...FieldExpr) Stage {
+	body := make(bson.D, 0, 3)
+	if partitionBy != nil {
+		body = append(body, bson.E{Key: "partitionBy", Value: partitionBy})
+	}
+	if len(sortBy) > 0 {
+		body = append(body, bson.E{Key: "sortBy", Value: sortBysToD(sortBy)})
+	}
+	body = append(body, bson.E{Key: "output", Value: fieldExprsToD(output)})
+
+	return Stage{{Key: "$setWindowFields", Value: body}}
+}
+
+// trailing returns a document window of the n documents up to and including
+// the current document.
+func trailing(n int64) WindowBounds {
+	if n < 1 {
+		n = 1
+	}
+	return Documents(Offset(-(n - 1)), Current)
+}
+
+// MovingAvg returns the average of expr over the current document and the
+// previous window-1 documents, for use as a SetWindowFields output field. The
+// SetWindowFields stage must specify sortBy.
+//
+// For example, to compute the 7-day moving average of daily sales per store:
+//
+//	agg.SetWindowFields(
+//		"$store",
+//		[]agg.SortBy{agg.SortAscending("day")},
+//		agg.Field("avgSales", agg.MovingAvg("$sales", 7)),
+//	)
+func MovingAvg(expr any, window int64) Operator {
+	return Window(Avg(expr), trailing(window))
+}
+
+// RollingSum returns the sum of expr over the current document and the
+// previous window-1 documents, for use as a SetWindowFields output field. The
+// SetWindowFields stage must specify sortBy.
+func RollingSum(expr any, window int64) Operator {
+	return Window(Sum(expr), trailing(window))
+}
+
+// MovingAvgRange returns the average of expr over documents whose sortBy
+// value is within the amount of unit before the current document's, for use
+// as a SetWindowFields output field. The SetWindowFields stage must sort by a
+// single date field.
+func MovingAvgRange(expr any, amount int64, unit TimeUnit) Operator {
+	return Window(Avg(expr), Range(Offset(-amount), Current, unit))
+}
+
+// RollingSumRange returns the sum of expr over documents whose sortBy value is
+// within the amount of unit before the current document's, for use as a
+// SetWindowFields output field. The SetWindowFields stage must sort by a
+// single date field.
+func RollingSumRange(expr any, amount int64, unit TimeUnit) Operator {
+	return Window(Sum(expr), Range(Offset(-amount), Current, unit))
+}
+
+// CumulativeSum returns the running total of expr from the first document in
+// the partition through the current document.
+func CumulativeSum(expr any) Operator {
+	return Window(Sum(expr), Documents(Unbounded, Current))
+}
+
+// CumulativeSumStage returns a SetWindowFields stage that records the running
+// total of expr in outputField, partitioned by partitionBy and ordered by
+// sortBy.
+func CumulativeSumStage(outputField string, expr any, partitionBy any, sortBy ...SortBy) Stage {
+	return SetWindowFields(partitionBy, sortBy, Field(outputField, CumulativeSum(expr)))
+}
+
+// MinMaxScaler is a window operator that linearly scales inputExpr so the
+// smallest value in the window becomes min and the largest becomes max,
+// commonly 0 and 1. Requires MongoDB 8.2.
+func MinMaxScaler(inputExpr any, min, max float64) Operator {
+	return Operator{{
+		Key: "$minMaxScaler",
+		Value: bson.D{
+			{Key: "input", Value: inputExpr},
+			{Key: "min", Value: min},
+			{Key: "max", Value: max},
+		},
+	}}
+}
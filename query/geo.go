@@ -0,0 +1,27 @@
+package query
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// GeoWithin returns a query filter that matches documents with geospatial
+// data in field entirely within the GeoJSON geometry, like a geo.Polygon.
+func GeoWithin(field string, geometry any) bson.D {
+	return bson.D{{
+		Key: field,
+		Value: bson.D{{
+			Key:   "$geoWithin",
+			Value: bson.D{{Key: "$geometry", Value: geometry}},
+		}},
+	}}
+}
+
+// GeoIntersects returns a query filter that matches documents with geospatial
+// data in field that intersects the GeoJSON geometry.
+func GeoIntersects(field string, geometry any) bson.D {
+	return bson.D{{
+		Key: field,
+		Value: bson.D{{
+			Key:   "$geoIntersects",
+			Value: bson.D{{Key: "$geometry", Value: geometry}},
+		}},
+	}}
+}
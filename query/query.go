@@ -0,0 +1,16 @@
+// Package query provides builders for query filters used with Find and
+// similar CRUD operations and in the $match aggregation stage.
+package query
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Expr returns a query filter that matches documents for which the
+// aggregation expression evaluates to true, allowing filters that compare
+// fields of the same document.
+//
+// For example:
+//
+//	coll.Find(ctx, query.Expr(agg.Gt("$spent", "$budget")))
+func Expr(expr any) bson.D {
+	return bson.D{{Key: "$expr", Value: expr}}
+}
@@ -0,0 +1,31 @@
+package query
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// TextOptions are optional $text search parameters.
+type TextOptions struct {
+	// Language determines the stop words and stemming rules. Defaults to the
+	// text index's default language.
+	Language string
+
+	CaseSensitive      *bool
+	DiacriticSensitive *bool
+}
+
+// Text returns a $text query filter that searches text-indexed fields for
+// search. The opts can be nil.
+func Text(search string, opts *TextOptions) bson.D {
+	body := bson.D{{Key: "$search", Value: search}}
+	if opts != nil {
+		if opts.Language != "" {
+			body = append(body, bson.E{Key: "$language", Value: opts.Language})
+		}
+		if opts.CaseSensitive != nil {
+			body = append(body, bson.E{Key: "$caseSensitive", Value: *opts.CaseSensitive})
+		}
+		if opts.DiacriticSensitive != nil {
+			body = append(body, bson.E{Key: "$diacriticSensitive", Value: *opts.DiacriticSensitive})
+		}
+	}
+	return bson.D{{Key: "$text", Value: body}}
+}
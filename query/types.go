@@ -0,0 +1,93 @@
+package query
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// BSONType is a BSON type name, as used by the $type query operator and the
+// $type and $convert aggregation operators.
+type BSONType string
+
+const (
+	TypeDouble              BSONType = "double"
+	TypeString              BSONType = "string"
+	TypeObject              BSONType = "object"
+	TypeArray               BSONType = "array"
+	TypeBinData             BSONType = "binData"
+	TypeUndefined           BSONType = "undefined"
+	TypeObjectID            BSONType = "objectId"
+	TypeBool                BSONType = "bool"
+	TypeDate                BSONType = "date"
+	TypeNull                BSONType = "null"
+	TypeRegex               BSONType = "regex"
+	TypeDBPointer           BSONType = "dbPointer"
+	TypeJavaScript          BSONType = "javascript"
+	TypeSymbol              BSONType = "symbol"
+	TypeJavaScriptWithScope BSONType = "javascriptWithScope"
+	TypeInt                 BSONType = "int"
+	TypeTimestamp           BSONType = "timestamp"
+	TypeLong                BSONType = "long"
+	TypeDecimal             BSONType = "decimal"
+	TypeMinKey              BSONType = "minKey"
+	TypeMaxKey              BSONType = "maxKey"
+
+	// TypeNumber matches any numeric type in the $type query operator. It
+	// can't be used with $convert or compared to the result of $type.
+	TypeNumber BSONType = "number"
+	// TypeMissing is the result of the $type aggregation operator for a
+	// missing field. It can't be used with the $type query operator.
+	TypeMissing BSONType = "missing"
+)
+
+var typeNumbers = map[BSONType]int32{
+	TypeDouble:              1,
+	TypeString:              2,
+	TypeObject:              3,
+	TypeArray:               4,
+	TypeBinData:             5,
+	TypeUndefined:           6,
+	TypeObjectID:            7,
+	TypeBool:                8,
+	TypeDate:                9,
+	TypeNull:                10,
+	TypeRegex:               11,
+	TypeDBPointer:           12,
+	TypeJavaScript:          13,
+	TypeSymbol:              14,
+	TypeJavaScriptWithScope: 15,
+	TypeInt:                 16,
+	TypeTimestamp:           17,
+	TypeLong:                18,
+	TypeDecimal:             19,
+	TypeMinKey:              -1,
+	TypeMaxKey:              127,
+}
+
+// Number returns the numeric alias of the type, like 2 for TypeString. It
+// returns false for TypeNumber, TypeMissing, and unknown types, which have no
+// numeric alias.
+func (t BSONType) Number() (int32, bool) {
+	n, ok := typeNumbers[t]
+	return n, ok
+}
+
+// BSONTypeFromNumber returns the type with the numeric alias n.
+func BSONTypeFromNumber(n int32) (BSONType, bool) {
+	for t, tn := range typeNumbers {
+		if tn == n {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// Type returns a query filter that matches documents where field is any of
+// the given BSON types. For arrays, it also matches if any element is one of
+// the types.
+func Type(field string, types ...BSONType) bson.D {
+	var t any
+	if len(types) == 1 {
+		t = types[0]
+	} else {
+		t = append([]BSONType(nil), types...)
+	}
+	return bson.D{{Key: field, Value: bson.D{{Key: "$type", Value: t}}}}
+}
@@ -0,0 +1,226 @@
+// Package schema provides a builder for $jsonSchema documents, which can be
+// used as query filters (with Find or agg.Match) and as collection validators.
+//
+// For example:
+//
+//	s := schema.Object().
+//		Required("name", "age").
+//		Property("name", schema.String().MinLength(1)).
+//		Property("age", schema.Int().Minimum(0)).
+//		Property("tags", schema.Array(schema.String()).UniqueItems(true))
+//
+//	db.CreateCollection(ctx, "people", options.CreateCollection().SetValidator(s.Filter()))
+//	coll.Find(ctx, schema.Not(s).Filter())
+package schema
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Schema is a $jsonSchema document. Schema values are immutable; every
+// method returns a new Schema.
+type Schema struct {
+	d bson.D
+}
+
+// New returns an empty schema, which matches any value.
+func New() Schema {
+	return Schema{}
+}
+
+// Of returns a schema that matches values of any of the BSON types, like
+// "string" or "objectId".
+func Of(bsonTypes ...string) Schema {
+	return New().BSONType(bsonTypes...)
+}
+
+func Object() Schema   { return Of("object") }
+func String() Schema   { return Of("string") }
+func Int() Schema      { return Of("int") }
+func Long() Schema     { return Of("long") }
+func Double() Schema   { return Of("double") }
+func Decimal() Schema  { return Of("decimal") }
+func Number() Schema   { return Of("number") }
+func Bool() Schema     { return Of("bool") }
+func Date() Schema     { return Of("date") }
+func ObjectID() Schema { return Of("objectId") }
+func Null() Schema     { return Of("null") }
+
+// Array returns a schema that matches arrays whose items all match items.
+func Array(items Schema) Schema {
+	return Of("array").Items(items)
+}
+
+// Not returns a schema that matches values that don't match s.
+func Not(s Schema) Schema {
+	return New().set("not", s)
+}
+
+// AnyOf returns a schema that matches values that match any of the schemas.
+func AnyOf(schemas ...Schema) Schema {
+	return New().set("anyOf", schemaArray(schemas))
+}
+
+// AllOf returns a schema that matches values that match all of the schemas.
+func AllOf(schemas ...Schema) Schema {
+	return New().set("allOf", schemaArray(schemas))
+}
+
+// OneOf returns a schema that matches values that match exactly one of the
+// schemas.
+func OneOf(schemas ...Schema) Schema {
+	return New().set("oneOf", schemaArray(schemas))
+}
+
+func (s Schema) BSONType(bsonTypes ...string) Schema {
+	if len(bsonTypes) == 1 {
+		return s.set("bsonType", bsonTypes[0])
+	}
+	return s.set("bsonType", append([]string(nil), bsonTypes...))
+}
+
+func (s Schema) Title(title string) Schema {
+	return s.set("title", title)
+}
+
+func (s Schema) Description(description string) Schema {
+	return s.set("description", description)
+}
+
+// Required adds the fields to the list of required object properties.
+func (s Schema) Required(fields ...string) Schema {
+	var required []string
+	if v, ok := s.get("required"); ok {
+		required = v.([]string)
+	}
+	return s.set("required", append(append([]string(nil), required...), fields...))
+}
+
+// Property sets the schema for the named object property.
+func (s Schema) Property(name string, prop Schema) Schema {
+	var props bson.D
+	if v, ok := s.get("properties"); ok {
+		props = v.(bson.D)
+	}
+	return s.set("properties", setKey(props, name, prop))
+}
+
+func (s Schema) AdditionalProperties(allowed bool) Schema {
+	return s.set("additionalProperties", allowed)
+}
+
+func (s Schema) MinProperties(n int64) Schema {
+	return s.set("minProperties", n)
+}
+
+func (s Schema) MaxProperties(n int64) Schema {
+	return s.set("maxProperties", n)
+}
+
+func (s Schema) Items(items Schema) Schema {
+	return s.set("items", items)
+}
+
+func (s Schema) MinItems(n int64) Schema {
+	return s.set("minItems", n)
+}
+
+func (s Schema) MaxItems(n int64) Schema {
+	return s.set("maxItems", n)
+}
+
+func (s Schema) UniqueItems(unique bool) Schema {
+	return s.set("uniqueItems", unique)
+}
+
+func (s Schema) Pattern(pattern string) Schema {
+	return s.set("pattern", pattern)
+}
+
+func (s Schema) MinLength(n int64) Schema {
+	return s.set("minLength", n)
+}
+
+func (s Schema) MaxLength(n int64) Schema {
+	return s.set("maxLength", n)
+}
+
+// Enum restricts values to the listed values.
+func (s Schema) Enum(values ...any) Schema {
+	return s.set("enum", bson.A(append([]any(nil), values...)))
+}
+
+func (s Schema) Minimum(n any) Schema {
+	return s.set("minimum", n)
+}
+
+func (s Schema) ExclusiveMinimum(n any) Schema {
+	return s.set("minimum", n).set("exclusiveMinimum", true)
+}
+
+func (s Schema) Maximum(n any) Schema {
+	return s.set("maximum", n)
+}
+
+func (s Schema) ExclusiveMaximum(n any) Schema {
+	return s.set("maximum", n).set("exclusiveMaximum", true)
+}
+
+func (s Schema) MultipleOf(n any) Schema {
+	return s.set("multipleOf", n)
+}
+
+// D returns the schema document.
+func (s Schema) D() bson.D {
+	if s.d == nil {
+		return bson.D{}
+	}
+	return s.d
+}
+
+func (s Schema) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(s.D())
+}
+
+// Filter returns a {$jsonSchema: s} document, which can be used as a query
+// filter or a collection validator.
+func (s Schema) Filter() bson.D {
+	return bson.D{{Key: "$jsonSchema", Value: s}}
+}
+
+func (s Schema) get(key string) (any, bool) {
+	for _, e := range s.d {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (s Schema) set(key string, value any) Schema {
+	return Schema{d: setKey(s.d, key, value)}
+}
+
+// setKey returns a copy of d with key set to value, replacing any existing
+// value for key.
+func setKey(d bson.D, key string, value any) bson.D {
+	c := make(bson.D, 0, len(d)+1)
+	replaced := false
+	for _, e := range d {
+		if e.Key == key {
+			e.Value = value
+			replaced = true
+		}
+		c = append(c, e)
+	}
+	if !replaced {
+		c = append(c, bson.E{Key: key, Value: value})
+	}
+	return c
+}
+
+func schemaArray(schemas []Schema) bson.A {
+	a := make(bson.A, len(schemas))
+	for i := range schemas {
+		a[i] = schemas[i]
+	}
+	return a
+}